@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	ansiHTML  = "html"
+	ansiStrip = "strip"
+	ansiKeep  = "keep"
+)
+
+// ansiPattern matches the CSI escape sequences, SGR ones end with m
+var ansiPattern = regexp.MustCompile("\x1b\\[([0-9;?]*)([@-~])")
+
+// ansiColors are the names of the 8 standard colors, used as CSS classes
+var ansiColors = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// stripANSI removes the escape sequences of text.
+func stripANSI(text string) string {
+	if !strings.Contains(text, "\x1b") {
+		return text
+	}
+	return ansiPattern.ReplaceAllString(text, "")
+}
+
+// ansiStyle is the graphic rendition state while converting a line
+type ansiStyle struct {
+	bold       bool
+	faint      bool
+	italic     bool
+	underline  bool
+	foreground string
+	background string
+}
+
+// xterm256Color returns the CSS color of a 256 colors palette index.
+func xterm256Color(index int) string {
+	switch {
+	case index < 16:
+		// Standard and bright colors are left to the stylesheet
+		return ""
+	case index < 232:
+		index -= 16
+		levels := []int{0, 95, 135, 175, 215, 255}
+		return fmt.Sprintf("rgb(%d,%d,%d)", levels[index/36], levels[index/6%6], levels[index%6])
+	case index < 256:
+		gray := 8 + (index-232)*10
+		return fmt.Sprintf("rgb(%d,%d,%d)", gray, gray, gray)
+	}
+	return ""
+}
+
+// apply updates the style with the parameters of a SGR sequence.
+func (s *ansiStyle) apply(parameters string) {
+	codes := strings.Split(parameters, ";")
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil {
+			code = 0
+		}
+		switch {
+		case code == 0:
+			*s = ansiStyle{}
+		case code == 1:
+			s.bold = true
+		case code == 2:
+			s.faint = true
+		case code == 3:
+			s.italic = true
+		case code == 4:
+			s.underline = true
+		case code == 22:
+			s.bold, s.faint = false, false
+		case code == 23:
+			s.italic = false
+		case code == 24:
+			s.underline = false
+		case code >= 30 && code <= 37:
+			s.foreground = "ansi-fg-" + ansiColors[code-30]
+		case code >= 90 && code <= 97:
+			s.foreground = "ansi-fg-bright-" + ansiColors[code-90]
+		case code == 39:
+			s.foreground = ""
+		case code >= 40 && code <= 47:
+			s.background = "ansi-bg-" + ansiColors[code-40]
+		case code >= 100 && code <= 107:
+			s.background = "ansi-bg-bright-" + ansiColors[code-100]
+		case code == 49:
+			s.background = ""
+		case code == 38 || code == 48:
+			// Extended colors: 5;index or 2;r;g;b
+			color, prefix := "", "ansi-fg-"
+			if code == 48 {
+				prefix = "ansi-bg-"
+			}
+			if i+2 < len(codes) && codes[i+1] == "5" {
+				index, _ := strconv.Atoi(codes[i+2])
+				if index < 16 {
+					color = prefix + ansiIndexedName(index)
+				} else {
+					color = "rgb:" + xterm256Color(index)
+				}
+				i += 2
+			} else if i+4 < len(codes) && codes[i+1] == "2" {
+				r, _ := strconv.Atoi(codes[i+2])
+				g, _ := strconv.Atoi(codes[i+3])
+				b, _ := strconv.Atoi(codes[i+4])
+				color = fmt.Sprintf("rgb:rgb(%d,%d,%d)", r&255, g&255, b&255)
+				i += 4
+			}
+			if code == 38 {
+				s.foreground = color
+			} else {
+				s.background = color
+			}
+		}
+	}
+}
+
+func ansiIndexedName(index int) string {
+	if index >= 8 {
+		return "bright-" + ansiColors[index-8]
+	}
+	return ansiColors[index]
+}
+
+// span returns the opening tag of the style, empty for the default style.
+func (s ansiStyle) span() string {
+	var classes, styles []string
+	for _, color := range []struct {
+		value    string
+		property string
+	}{{s.foreground, "color"}, {s.background, "background-color"}} {
+		switch {
+		case strings.HasPrefix(color.value, "rgb:"):
+			styles = append(styles, color.property+":"+strings.TrimPrefix(color.value, "rgb:"))
+		case color.value != "":
+			classes = append(classes, color.value)
+		}
+	}
+	for _, flag := range []struct {
+		set   bool
+		class string
+	}{{s.bold, "ansi-bold"}, {s.faint, "ansi-faint"}, {s.italic, "ansi-italic"}, {s.underline, "ansi-underline"}} {
+		if flag.set {
+			classes = append(classes, flag.class)
+		}
+	}
+	if len(classes) == 0 && len(styles) == 0 {
+		return ""
+	}
+	tag := "<span"
+	if len(classes) > 0 {
+		tag += ` class="` + strings.Join(classes, " ") + `"`
+	}
+	if len(styles) > 0 {
+		tag += ` style="` + strings.Join(styles, ";") + `"`
+	}
+	return tag + ">"
+}
+
+// ansiToHTML escapes text and converts its SGR sequences into spans.
+// Other escape sequences are dropped.
+func ansiToHTML(text string) string {
+	if !strings.Contains(text, "\x1b") {
+		return html.EscapeString(text)
+	}
+	var b strings.Builder
+	var style ansiStyle
+	open := false
+	last := 0
+	for _, match := range ansiPattern.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:match[0]]))
+		last = match[1]
+		if text[match[4]:match[5]] != "m" {
+			continue
+		}
+		style.apply(text[match[2]:match[3]])
+		if open {
+			b.WriteString("</span>")
+			open = false
+		}
+		if tag := style.span(); tag != "" {
+			b.WriteString(tag)
+			open = true
+		}
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	if open {
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
+
+func parseANSIMode(value, defaultMode string, modes ...string) (string, error) {
+	if value == "" {
+		return defaultMode, nil
+	}
+	for _, mode := range modes {
+		if value == mode {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("unknown ansi mode %q", value)
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestANSIToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "plain", text: "a < b & c", want: "a &lt; b &amp; c"},
+		{name: "color and reset", text: "\x1b[31merror\x1b[0m done", want: `<span class="ansi-fg-red">error</span> done`},
+		{name: "bold bright", text: "\x1b[1;92mok\x1b[m", want: `<span class="ansi-fg-bright-green ansi-bold">ok</span>`},
+		{name: "background", text: "\x1b[44;37mx\x1b[49my", want: `<span class="ansi-fg-white ansi-bg-blue">x</span><span class="ansi-fg-white">y</span>`},
+		{name: "256 colors", text: "\x1b[38;5;196mhot\x1b[38;5;9mred", want: `<span style="color:rgb(255,0,0)">hot</span><span class="ansi-fg-bright-red">red</span>`},
+		{name: "grayscale", text: "\x1b[48;5;232mx", want: `<span style="background-color:rgb(8,8,8)">x</span>`},
+		{name: "true color", text: "\x1b[38;2;10;20;300mx", want: `<span style="color:rgb(10,20,44)">x</span>`},
+		{name: "attributes off", text: "\x1b[1;3;4ma\x1b[22;23;24mb", want: `<span class="ansi-bold ansi-italic ansi-underline">a</span>b`},
+		{name: "escaped text inside", text: "\x1b[33m<b>\x1b[0m", want: `<span class="ansi-fg-yellow">&lt;b&gt;</span>`},
+		{name: "other sequences dropped", text: "\x1b[2K\x1b[1Gprogress", want: "progress"},
+		{name: "unterminated", text: "\x1b[32mstill open", want: `<span class="ansi-fg-green">still open</span>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ansiToHTML(test.text); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	if got, want := stripANSI("\x1b[1;31mfailed\x1b[0m \x1b[2Kdone"), "failed done"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseANSIMode(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		err   bool
+	}{
+		{value: "", want: ansiHTML},
+		{value: ansiStrip, want: ansiStrip},
+		{value: ansiKeep, err: true},
+	}
+	for _, test := range tests {
+		got, err := parseANSIMode(test.value, ansiHTML, ansiHTML, ansiStrip)
+		if got != test.want || (err != nil) != test.err {
+			t.Errorf("%q got %q, %v, want %q, error %t", test.value, got, err, test.want, test.err)
+		}
+	}
+}
@@ -1,32 +1,125 @@
 const application = Stimulus.Application.start();
 
 class LogsController extends Stimulus.Controller {
+  static get targets() {
+    return ["form", "download", "lines", "older"];
+  }
+
   connect() {
+    this.open(this.parameters(), false);
+  }
+
+  parameters() {
+    return new URLSearchParams(new FormData(this.formTarget));
+  }
+
+  open(parameters, older) {
+    this.close();
     console.log("Connecting to logs event source.");
-    this.eventSource = new EventSource("/logs/events");
+    this.olderTarget.hidden = true;
+    this.buffer = older ? document.createDocumentFragment() : null;
+    this.eventSource = new EventSource("/logs/events?" + parameters.toString());
     this.eventSource.onopen = this.onOpen;
     this.eventSource.onmessage = this.onMessage.bind(this);
+    this.eventSource.addEventListener("end", this.onEnd.bind(this));
     this.eventSource.onerror = this.onError;
   }
 
+  reload(event) {
+    event.preventDefault();
+    const parameters = this.parameters();
+    history.replaceState(null, "", window.location.pathname + "?" + parameters.toString());
+    this.linesTarget.innerHTML = "";
+    this.open(parameters, false);
+  }
+
+  download(event) {
+    event.preventDefault();
+    const parameters = this.parameters();
+    parameters.delete("ansi");
+    new FormData(this.downloadTarget).forEach((value, key) => parameters.set(key, value));
+    parameters.delete("follow");
+    window.location = "/logs/download?" + parameters.toString();
+  }
+
+  loadOlder() {
+    const parameters = this.parameters();
+    parameters.set("follow", "false");
+    parameters.set("until", this.until);
+    parameters.delete("since");
+    this.open(parameters, true);
+  }
+
   onOpen(event) {
     console.log("Connected to logs event source.");
   }
 
   onMessage(message) {
-    console.log("onMessage", message);
-    var newElement = document.createElement("p");
-    newElement.textContent = message.data;
-    this.element.appendChild(newElement);
+    const entry = JSON.parse(message.data);
+    const newElement = document.createElement("p");
+    newElement.className = "log-line " + entry.stream;
+    if (entry.rules) {
+      newElement.classList.add("highlighted");
+      newElement.title = entry.rules.join(", ");
+    }
+    if (entry.name) {
+      this.appendSpan(newElement, "log-prefix " + entry.color, entry.name);
+    }
+    if (entry.time) {
+      this.appendSpan(newElement, "log-time", entry.time);
+    }
+    if (entry.columns) {
+      entry.columns.forEach(value => this.appendSpan(newElement, "log-column", value));
+    } else if (entry.html) {
+      // Escaped and converted from ANSI sequences by the server
+      this.appendSpan(newElement, "log-text", "").innerHTML = entry.html;
+    } else {
+      this.appendSpan(newElement, "log-text", entry.text);
+    }
+    if (entry.fields && Object.keys(entry.fields).length > 0) {
+      const details = document.createElement("pre");
+      details.className = "log-fields";
+      details.hidden = true;
+      details.textContent = JSON.stringify(entry.fields, null, 2);
+      newElement.appendChild(details);
+      newElement.classList.add("expandable");
+      newElement.addEventListener("click", () => (details.hidden = !details.hidden));
+    }
+    (this.buffer || this.linesTarget).appendChild(newElement);
+  }
+
+  appendSpan(parent, className, text) {
+    const span = document.createElement("span");
+    span.className = className;
+    span.textContent = text;
+    parent.appendChild(span);
+    return span;
+  }
+
+  onEnd(event) {
+    this.close();
+    if (this.buffer) {
+      this.linesTarget.insertBefore(this.buffer, this.linesTarget.firstChild);
+      this.buffer = null;
+    }
+    this.until = event.data;
+    this.olderTarget.hidden = !this.until;
   }
 
   onError(error) {
     console.error("Logs event source error.", error);
   }
 
+  close() {
+    if (this.eventSource) {
+      this.eventSource.close();
+      this.eventSource = null;
+    }
+  }
+
   disconnect() {
     console.log("Closing logs event source.");
-    this.eventSource.close();
+    this.close();
   }
 }
 application.register("logs", LogsController);
@@ -168,12 +261,21 @@ class EventsController extends Stimulus.Controller {
     this.eventSource.onopen = this.onOpen;
     this.eventSource.onmessage = this.onMessage.bind(this);
     this.eventSource.onerror = this.onError;
+    this.eventSource.addEventListener("log_alert", this.onLogAlert.bind(this));
   }
 
   onOpen(event) {
     console.log("Connected events source.");
   }
 
+  onLogAlert(message) {
+    const alert = JSON.parse(message.data);
+    const element = document.createElement("p");
+    element.className = "alert";
+    element.textContent = `${alert.name}: ${alert.rule} matched "${alert.line}"`;
+    this.element.insertBefore(element, this.element.firstChild);
+  }
+
   onMessage(message) {
     console.log("onMessage");
     Turbolinks.visit();
@@ -207,3 +309,257 @@ class ImagesController extends Stimulus.Controller {
   }
 }
 application.register("images", ImagesController);
+
+class BulkController extends Stimulus.Controller {
+  static get targets() {
+    return ["item"];
+  }
+
+  toggle(event) {
+    this.itemTargets.forEach(item => (item.checked = event.target.checked));
+  }
+
+  confirm(event) {
+    const action = this.element.querySelector("select[name=action]").value;
+    const view = this.element.querySelector("input[name=view]");
+    const selection = view ? `every object of the ${view.value} view` : `${this.itemTargets.filter(item => item.checked).length} objects`;
+    if (!window.confirm(`${action} ${selection}?`)) {
+      event.preventDefault();
+    }
+  }
+}
+application.register("bulk", BulkController);
+
+class BulkReportController extends Stimulus.Controller {
+  static get targets() {
+    return ["summary"];
+  }
+
+  connect() {
+    this.counts = { done: 0, skipped: 0, failed: 0 };
+    this.eventSource = new EventSource(`/bulk/${this.data.get("id")}/events`);
+    this.eventSource.onmessage = this.onResult.bind(this);
+    this.eventSource.addEventListener("end", this.onEnd.bind(this));
+    this.eventSource.onerror = error => console.error("Bulk events source error.", error);
+  }
+
+  onResult(message) {
+    const result = JSON.parse(message.data);
+    this.counts[result.status]++;
+    const row = document.getElementById(`${result.kind}/${result.id}`);
+    if (row) {
+      const cell = row.querySelector(".bulk-result");
+      cell.className = `bulk-result ${result.status}`;
+      cell.textContent = result.message ? `${result.status}: ${result.message}` : result.status;
+    }
+    this.summarize("Running…");
+  }
+
+  onEnd() {
+    this.eventSource.close();
+    this.summarize("Done.");
+  }
+
+  summarize(state) {
+    const { done, skipped, failed } = this.counts;
+    this.summaryTarget.textContent = `${state} ${done} done, ${skipped} skipped, ${failed} failed.`;
+  }
+
+  disconnect() {
+    this.eventSource.close();
+  }
+}
+application.register("bulk-report", BulkReportController);
+
+// shortRowID shortens a row ID the way the list events do.
+function shortRowID(id) {
+  return id.replace(/^sha256:/, "").slice(0, 12);
+}
+
+class ListController extends Stimulus.Controller {
+  connect() {
+    // The rows rendered are sent again in case they changed since
+    const parameters = new URLSearchParams(window.location.search);
+    parameters.set("rendered", Array.from(this.element.rows, row => shortRowID(row.id)).join(","));
+    this.eventSource = new EventSource(this.data.get("url") + "?" + parameters.toString());
+    this.eventSource.addEventListener("row", this.onRow.bind(this));
+    this.eventSource.addEventListener("add", this.onAdd.bind(this));
+    this.eventSource.addEventListener("remove", this.onRemove.bind(this));
+    this.eventSource.onerror = error => console.error("List events source error.", error);
+  }
+
+  render(html) {
+    const body = document.createElement("tbody");
+    body.innerHTML = html.trim();
+    return body.firstElementChild;
+  }
+
+  replace(current, html) {
+    const row = this.render(html);
+    const checked = current.querySelector("input[name=item]:checked");
+    if (checked) {
+      row.querySelector("input[name=item]").checked = true;
+    }
+    current.replaceWith(row);
+  }
+
+  // find returns the row of an ID or a short ID.
+  find(id) {
+    return Array.from(this.element.rows).find(row => row.id === id || shortRowID(row.id) === id);
+  }
+
+  onRow(message) {
+    const row = JSON.parse(message.data);
+    const current = this.find(row.id);
+    // Rows of the other pages aren't shown
+    if (current) {
+      this.replace(current, row.html);
+    }
+  }
+
+  onAdd(message) {
+    const row = JSON.parse(message.data);
+    const current = this.find(row.id);
+    if (current) {
+      this.replace(current, row.html);
+      return;
+    }
+    // Where the row belongs depends on the sort and the pagination
+    if (!this.notice) {
+      const columns = this.element.closest("table").tHead.rows[0].cells.length;
+      this.notice = this.render(`<tr class="list-notice"><td colspan="${columns}"><a href="">New items, reload</a></td></tr>`);
+      this.element.insertBefore(this.notice, this.element.firstChild);
+    }
+  }
+
+  onRemove(message) {
+    const row = JSON.parse(message.data);
+    const current = this.find(row.id);
+    if (current) {
+      current.remove();
+    }
+  }
+
+  disconnect() {
+    this.eventSource.close();
+  }
+}
+application.register("list", ListController);
+
+class SuggestController extends Stimulus.Controller {
+  static get targets() {
+    return ["input", "list"];
+  }
+
+  connect() {
+    this.suggestions = [];
+    this.selected = -1;
+    this.onShortcut = event => {
+      const tag = document.activeElement.tagName;
+      if (event.key === "/" && tag !== "INPUT" && tag !== "TEXTAREA" && tag !== "SELECT") {
+        event.preventDefault();
+        this.inputTarget.focus();
+      }
+    };
+    document.addEventListener("keydown", this.onShortcut);
+  }
+
+  complete() {
+    clearTimeout(this.timer);
+    this.timer = setTimeout(() => {
+      const q = this.inputTarget.value.trim();
+      if (!q) {
+        this.render([]);
+        return;
+      }
+      fetch("/search/suggest?q=" + encodeURIComponent(q))
+        .then(response => response.json())
+        .then(suggestions => this.render(suggestions))
+        .catch(error => console.error("Search suggestions error.", error));
+    }, 150);
+  }
+
+  render(suggestions) {
+    this.suggestions = suggestions;
+    this.selected = -1;
+    this.listTarget.innerHTML = "";
+    suggestions.forEach(suggestion => {
+      const item = document.createElement("li");
+      const link = document.createElement("a");
+      link.href = suggestion.url;
+      link.textContent = suggestion.text;
+      const kind = document.createElement("span");
+      kind.className = "suggestion-type";
+      kind.textContent = suggestion.type;
+      item.appendChild(link);
+      item.appendChild(kind);
+      this.listTarget.appendChild(item);
+    });
+    this.listTarget.hidden = suggestions.length === 0;
+  }
+
+  navigate(event) {
+    const items = this.listTarget.children;
+    switch (event.key) {
+      case "ArrowDown":
+        this.selected = Math.min(this.selected + 1, items.length - 1);
+        break;
+      case "ArrowUp":
+        this.selected = Math.max(this.selected - 1, -1);
+        break;
+      case "Enter":
+        if (this.selected >= 0) {
+          event.preventDefault();
+          Turbolinks.visit(this.suggestions[this.selected].url);
+        }
+        return;
+      case "Escape":
+        this.render([]);
+        this.inputTarget.blur();
+        return;
+      default:
+        return;
+    }
+    event.preventDefault();
+    Array.from(items).forEach((item, index) => item.classList.toggle("selected", index === this.selected));
+  }
+
+  disconnect() {
+    clearTimeout(this.timer);
+    document.removeEventListener("keydown", this.onShortcut);
+  }
+}
+application.register("suggest", SuggestController);
+
+class ViewsController extends Stimulus.Controller {
+  connect() {
+    fetch("/views", { headers: { Accept: "application/json" } })
+      .then(response => response.json())
+      .then(views => this.render(views))
+      .catch(error => console.error("Views error.", error));
+  }
+
+  render(views) {
+    this.element.innerHTML = "";
+    views.forEach(view => {
+      const link = document.createElement("a");
+      link.href = view.url;
+      link.title = view.query;
+      link.textContent = view.count >= 0 ? `${view.name} (${view.count})` : view.name;
+      this.element.appendChild(link);
+    });
+  }
+}
+application.register("views", ViewsController);
+
+class RefreshController extends Stimulus.Controller {
+  connect() {
+    const interval = parseInt(this.data.get("interval") || "5000", 10);
+    this.timer = setInterval(() => Turbolinks.visit(window.location, { action: "replace" }), interval);
+  }
+
+  disconnect() {
+    clearInterval(this.timer);
+  }
+}
+application.register("refresh", RefreshController);
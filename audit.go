@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const auditEntriesLimit = 50
+
+// AuditChange is a single field modified by the console
+type AuditChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+// AuditEntry records the changes applied to an object at a given time
+type AuditEntry struct {
+	Time    time.Time
+	Changes []AuditChange
+}
+
+// auditLog keeps the latest changes made through the console, per object ID
+type auditLog struct {
+	mu      sync.Mutex
+	entries map[string][]AuditEntry
+}
+
+func newAuditLog() *auditLog {
+	return &auditLog{entries: make(map[string][]AuditEntry)}
+}
+
+func (a *auditLog) record(id string, changes []AuditChange) {
+	fields := log.Fields{"id": id}
+	for _, change := range changes {
+		fields[change.Field] = change.From + " -> " + change.To
+	}
+	log.WithFields(fields).Info("Updated")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entries := append(a.entries[id], AuditEntry{Time: time.Now(), Changes: changes})
+	if len(entries) > auditEntriesLimit {
+		entries = entries[len(entries)-auditEntriesLimit:]
+	}
+	a.entries[id] = entries
+}
+
+// list returns the recorded entries of an object, most recent first.
+func (a *auditLog) list(id string) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entries := a.entries[id]
+	list := make([]AuditEntry, len(entries))
+	for index := range entries {
+		list[len(entries)-1-index] = entries[index]
+	}
+	return list
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	stopAction   = "stop"
+	removeAction = "remove"
+	// pruneAction removes the selected objects which aren't used
+	pruneAction = "prune"
+
+	// bulkConcurrency bounds the Docker calls of a bulk action running at once
+	bulkConcurrency   = 4
+	bulkActionTimeout = time.Minute
+	bulkJobsLimit     = 20
+	bulkSelectionSize = 1000
+
+	doneResult    = "done"
+	skippedResult = "skipped"
+	failedResult  = "failed"
+)
+
+// bulkActions are the actions supported by each kind of object
+var bulkActions = map[string][]string{
+	containersIndexName: {stopAction, removeAction, pruneAction},
+	imagesIndexName:     {removeAction, pruneAction},
+	volumesIndexName:    {removeAction, pruneAction},
+}
+
+// bulkItem is an object selected for a bulk action
+type bulkItem struct {
+	Kind string
+	ID   string
+}
+
+// parseBulkItem parses the kind/id values of the selection checkboxes.
+func parseBulkItem(value string) (bulkItem, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return bulkItem{}, fmt.Errorf("invalid selection %q", value)
+	}
+	return bulkItem{Kind: parts[0], ID: parts[1]}, nil
+}
+
+// supportsBulkAction tells whether an action applies to a kind of object,
+// or to any kind when kind is empty.
+func supportsBulkAction(kind, action string) bool {
+	for actionsKind, actions := range bulkActions {
+		if kind != "" && kind != actionsKind {
+			continue
+		}
+		for _, supported := range actions {
+			if supported == action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// uniqueBulkItems drops the items selected twice, such as checked objects
+// of a view also acted on.
+func uniqueBulkItems(items []bulkItem) []bulkItem {
+	seen := make(map[bulkItem]bool, len(items))
+	unique := items[:0]
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
+
+// BulkResult is the outcome of a bulk action on an object
+type BulkResult struct {
+	Kind    string `json:"kind"`
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// bulkJob runs an action on a selection and keeps the results for the
+// clients following it.
+type bulkJob struct {
+	ID      string
+	Action  string
+	Force   bool
+	Items   []bulkItem
+	Started time.Time
+
+	mu        sync.Mutex
+	results   []BulkResult
+	done      bool
+	listeners map[chan BulkResult]struct{}
+}
+
+// follow returns the results so far and, unless the job is done, a channel
+// of the next ones, closed when the job ends.
+func (j *bulkJob) follow() ([]BulkResult, chan BulkResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	results := append([]BulkResult(nil), j.results...)
+	if j.done {
+		return results, nil
+	}
+	ch := make(chan BulkResult, len(j.Items))
+	j.listeners[ch] = struct{}{}
+	return results, ch
+}
+
+func (j *bulkJob) unfollow(ch chan BulkResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.listeners[ch]; ok {
+		delete(j.listeners, ch)
+		close(ch)
+	}
+}
+
+func (j *bulkJob) report(result BulkResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.results = append(j.results, result)
+	// Listeners are buffered for every item, sending never blocks
+	for ch := range j.listeners {
+		ch <- result
+	}
+}
+
+func (j *bulkJob) finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.done = true
+	for ch := range j.listeners {
+		delete(j.listeners, ch)
+		close(ch)
+	}
+}
+
+// run applies the action to every item, bulkConcurrency at a time.
+func (j *bulkJob) run(s *Server) {
+	defer j.finish()
+	slots := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	for _, item := range j.Items {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(item bulkItem) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), bulkActionTimeout)
+			defer cancel()
+			result := BulkResult{Kind: item.Kind, ID: item.ID, Status: doneResult}
+			skipped, err := s.applyBulkAction(ctx, j.Action, j.Force, item)
+			switch {
+			case err != nil:
+				result.Status, result.Message = failedResult, err.Error()
+			case skipped != "":
+				result.Status, result.Message = skippedResult, skipped
+			}
+			j.report(result)
+		}(item)
+	}
+	wg.Wait()
+	log.WithFields(log.Fields{"action": j.Action, "items": len(j.Items)}).Info("Bulk action done")
+}
+
+// applyBulkAction acts on an item and returns why it was skipped, if it was.
+func (s *Server) applyBulkAction(ctx context.Context, action string, force bool, item bulkItem) (string, error) {
+	if !supportsBulkAction(item.Kind, action) {
+		return fmt.Sprintf("%s doesn't apply to %s", action, item.Kind), nil
+	}
+	switch item.Kind + "/" + action {
+	case containersIndexName + "/" + stopAction:
+		return "", s.docker.ContainerStop(ctx, item.ID, nil)
+	case containersIndexName + "/" + pruneAction:
+		container, err := s.docker.ContainerInspect(ctx, item.ID)
+		if err != nil {
+			return "", err
+		}
+		if container.State != nil && container.State.Running {
+			return "running", nil
+		}
+		fallthrough
+	case containersIndexName + "/" + removeAction:
+		return "", s.docker.ContainerRemove(ctx, item.ID, types.ContainerRemoveOptions{Force: force})
+	case imagesIndexName + "/" + pruneAction:
+		used, err := s.docker.ContainerList(ctx, types.ContainerListOptions{
+			All:     true,
+			Filters: filters.NewArgs(filters.Arg("ancestor", item.ID)),
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(used) > 0 {
+			return fmt.Sprintf("used by %d containers", len(used)), nil
+		}
+		fallthrough
+	case imagesIndexName + "/" + removeAction:
+		_, err := s.docker.ImageRemove(ctx, item.ID, types.ImageRemoveOptions{Force: force, PruneChildren: true})
+		return "", err
+	case volumesIndexName + "/" + pruneAction:
+		used, err := s.docker.ContainerList(ctx, types.ContainerListOptions{
+			All:     true,
+			Filters: filters.NewArgs(filters.Arg("volume", item.ID)),
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(used) > 0 {
+			return fmt.Sprintf("used by %d containers", len(used)), nil
+		}
+		fallthrough
+	case volumesIndexName + "/" + removeAction:
+		return "", s.docker.VolumeRemove(ctx, item.ID, force)
+	}
+	return "", fmt.Errorf("%s doesn't apply to %s", action, item.Kind)
+}
+
+// bulkJobs keeps the latest bulk jobs
+type bulkJobs struct {
+	mu   sync.Mutex
+	jobs []*bulkJob
+}
+
+func (b *bulkJobs) add(job *bulkJob) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.jobs = append(b.jobs, job)
+	if len(b.jobs) > bulkJobsLimit {
+		b.jobs = b.jobs[len(b.jobs)-bulkJobsLimit:]
+	}
+}
+
+func (b *bulkJobs) get(id string) *bulkJob {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, job := range b.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// viewSelection returns the objects matching a saved view.
+func (s *Server) viewSelection(name string) ([]bulkItem, error) {
+	view, ok := s.views.get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown view %q", name)
+	}
+	index := s.searchIndex()
+	if index == nil || !s.indexSync.searchable() {
+		return nil, errors.New("the search index isn't ready")
+	}
+	request := bleve.NewSearchRequestOptions(parseSearchQuery(view.Query), bulkSelectionSize, 0, false)
+	result, err := index.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]bulkItem, len(result.Hits))
+	for index, hit := range result.Hits {
+		items[index] = bulkItem{Kind: hit.Index, ID: hit.ID}
+	}
+	return items, nil
+}
+
+// handleBulk starts an action on the checked objects, and the objects of a
+// view, and redirects to its report. The objects the action doesn't apply
+// to are reported as skipped.
+func (s *Server) handleBulk() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		action := r.PostForm.Get("action")
+		if !supportsBulkAction("", action) {
+			http.Error(w, fmt.Sprintf("unknown action %q", action), http.StatusBadRequest)
+			return
+		}
+		force, _ := strconv.ParseBool(r.PostForm.Get("force"))
+		job := &bulkJob{
+			ID:        ksuid.New().String(),
+			Action:    action,
+			Force:     force,
+			Started:   time.Now(),
+			listeners: make(map[chan BulkResult]struct{}),
+		}
+		for _, value := range r.PostForm["item"] {
+			item, err := parseBulkItem(value)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			job.Items = append(job.Items, item)
+		}
+		if view := r.PostForm.Get("view"); view != "" {
+			items, err := s.viewSelection(view)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			job.Items = append(job.Items, items...)
+		}
+		job.Items = uniqueBulkItems(job.Items)
+		if len(job.Items) == 0 {
+			http.Error(w, "Nothing selected", http.StatusBadRequest)
+			return
+		}
+		s.bulk.add(job)
+		go job.run(s)
+		http.Redirect(w, r, "/bulk/"+job.ID, http.StatusSeeOther)
+	}
+}
+
+func (s *Server) handleBulkJob() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		init.Do(func() {
+			tpl, err = s.parseTemplate("bulk.html")
+		})
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		job := s.bulk.get(mux.Vars(r)["id"])
+		if job == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if err := tpl.ExecuteTemplate(w, "bulk.html", job); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// handleBulkEvents streams the results of a bulk job as server sent events,
+// ending with an "end" event.
+func (s *Server) handleBulkEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job := s.bulk.get(mux.Vars(r)["id"])
+		if job == nil {
+			http.NotFound(w, r)
+			return
+		}
+		f, ok := w.(http.Flusher)
+		if !ok {
+			log.Error("Streaming unsupported!")
+			http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+		send := func(result BulkResult) {
+			data, err := json.Marshal(result)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			fmt.Fprint(w, NewEvent("", string(data)))
+			f.Flush()
+		}
+		results, next := job.follow()
+		for _, result := range results {
+			send(result)
+		}
+		if next != nil {
+			defer job.unfollow(next)
+		following:
+			for {
+				select {
+				case result, ok := <-next:
+					if !ok {
+						break following
+					}
+					send(result)
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+		fmt.Fprint(w, NewEvent("end", ""))
+		f.Flush()
+	}
+}
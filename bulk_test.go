@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBulkItem(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bulkItem
+		err   bool
+	}{
+		{value: "containers/4c3f", want: bulkItem{Kind: containersIndexName, ID: "4c3f"}},
+		{value: "images/sha256:9e1f", want: bulkItem{Kind: imagesIndexName, ID: "sha256:9e1f"}},
+		{value: "volumes/data/cache", want: bulkItem{Kind: volumesIndexName, ID: "data/cache"}},
+		{value: "networks/bridge", want: bulkItem{Kind: networksIndexName, ID: "bridge"}},
+		{value: "4c3f", err: true},
+		{value: "containers/", err: true},
+		{value: "/4c3f", err: true},
+		{value: "", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parseBulkItem(test.value)
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v, want error %t", err, test.err)
+			}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSupportsBulkAction(t *testing.T) {
+	tests := []struct {
+		kind   string
+		action string
+		want   bool
+	}{
+		{kind: containersIndexName, action: stopAction, want: true},
+		{kind: imagesIndexName, action: pruneAction, want: true},
+		{kind: imagesIndexName, action: stopAction},
+		{kind: networksIndexName, action: removeAction},
+		{kind: "", action: stopAction, want: true},
+		{kind: "", action: "label"},
+	}
+	for _, test := range tests {
+		if got := supportsBulkAction(test.kind, test.action); got != test.want {
+			t.Errorf("%s of %q got %t, want %t", test.action, test.kind, got, test.want)
+		}
+	}
+}
+
+func TestUniqueBulkItems(t *testing.T) {
+	items := []bulkItem{
+		{Kind: containersIndexName, ID: "a"},
+		{Kind: containersIndexName, ID: "b"},
+		{Kind: containersIndexName, ID: "a"},
+		{Kind: volumesIndexName, ID: "a"},
+	}
+	want := []bulkItem{
+		{Kind: containersIndexName, ID: "a"},
+		{Kind: containersIndexName, ID: "b"},
+		{Kind: volumesIndexName, ID: "a"},
+	}
+	if got := uniqueBulkItems(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -2,31 +2,98 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	units "github.com/docker/go-units"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
+// minimumMemory is the smallest memory limit accepted by the daemon
+const minimumMemory = 6 * units.MiB
+
+// containerSortKeys are the sort keys of the containers list
+var containerSortKeys = []string{"name", "created", "size", "state", "image"}
+
+// containerRow is a row of the containers list
+type containerRow struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	ImageID     string `json:"image_id"`
+	Created     int64  `json:"created"`
+	State       string `json:"state"`
+	Size        int64  `json:"size,omitempty"`
+	StatusColor string `json:"-"`
+	CrashLoop   bool   `json:"crash_loop"`
+	OOMKilled   bool   `json:"oom_killed"`
+	Restarts    int    `json:"restarts"`
+}
+
+// listContainers returns the rows of the containers matching the query
+// filters, unsorted.
+func (s *Server) listContainers(ctx context.Context, query listQuery) ([]containerRow, error) {
+	args := filters.NewArgs()
+	if query.State != "" {
+		args.Add("status", query.State)
+	}
+	for _, label := range query.Labels {
+		args.Add("label", label)
+	}
+	// Computing the sizes is slow, they are only listed to sort by size
+	containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{All: true, Size: query.Sort == "size", Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]containerRow, len(containers))
+	for index, c := range containers {
+		rows[index] = containerRow{
+			ID:      c.ID,
+			Name:    c.Names[0][1:],
+			Image:   c.Image,
+			ImageID: c.ImageID,
+			Created: c.Created,
+			State:   c.State,
+			Size:    c.SizeRw,
+		}
+		alert := s.crashes.status(c.ID)
+		rows[index].CrashLoop = alert.CrashLoop
+		rows[index].OOMKilled = alert.OOMKilled
+		rows[index].Restarts = alert.Restarts
+		switch c.State {
+		case "paused":
+			rows[index].StatusColor = "yellow"
+		case "running":
+			rows[index].StatusColor = "green"
+		default:
+			rows[index].StatusColor = "red"
+		}
+	}
+	return rows, nil
+}
+
 func (s *Server) handleContainers() http.HandlerFunc {
 	var (
 		init sync.Once
 		tpl  *template.Template
 		err  error
 	)
-	type container struct {
-		ID          string
-		Name        string
-		Image       string
-		ImageID     string
-		StatusColor string
-	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		init.Do(func() {
@@ -37,35 +104,36 @@ func (s *Server) handleContainers() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{All: true})
+		query, err := parseListQuery(r, containerSortKeys, "name", ascendingOrder)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		containersResponse, err := s.listContainers(ctx, query)
 		if err != nil && err != context.Canceled {
 			logrus.Error("Docker containers list", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		containersResponse := make([]container, len(containers))
-		for index, c := range containers {
-			containersResponse[index] = container{
-				ID:      c.ID,
-				Name:    c.Names[0][1:],
-				Image:   c.Image,
-				ImageID: c.ImageID,
-			}
-			switch c.State {
-			case "paused":
-				containersResponse[index].StatusColor = "yellow"
-			case "running":
-				containersResponse[index].StatusColor = "green"
-			default:
-				containersResponse[index].StatusColor = "red"
+		query.sort(containersResponse, map[string]func(i, j int) bool{
+			"name":    func(i, j int) bool { return containersResponse[i].Name < containersResponse[j].Name },
+			"created": func(i, j int) bool { return containersResponse[i].Created < containersResponse[j].Created },
+			"size":    func(i, j int) bool { return containersResponse[i].Size < containersResponse[j].Size },
+			"state":   func(i, j int) bool { return containersResponse[i].State < containersResponse[j].State },
+			"image":   func(i, j int) bool { return containersResponse[i].Image < containersResponse[j].Image },
+		})
+		start, end, page := query.paginate(len(containersResponse))
+		page.Items = containersResponse[start:end]
+
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(page); err != nil {
+				logrus.Error(err)
 			}
+			return
 		}
-
-		sort.Slice(containersResponse, func(i, j int) bool { return containersResponse[i].Name < containersResponse[j].Name })
-
-		err = tpl.ExecuteTemplate(w, "containers.html", containersResponse)
-		if err != nil {
+		if err := tpl.ExecuteTemplate(w, "containers.html", page); err != nil {
 			logrus.Error(err)
 		}
 	}
@@ -80,7 +148,33 @@ func (s *Server) handleContainer() http.HandlerFunc {
 		tpl  *template.Template
 		err  error
 	)
+	type port struct {
+		Container string
+		Host      []string
+	}
+	type mount struct {
+		Type        string
+		Source      string
+		Destination string
+		Mode        string
+		RW          bool
+	}
+	type endpoint struct {
+		Network     string
+		IPAddress   string
+		IPv6Address string
+		Gateway     string
+		MacAddress  string
+		Aliases     []string
+	}
+	type probe struct {
+		Start    time.Time
+		End      time.Time
+		ExitCode int
+		Output   string
+	}
 	type containerResponse struct {
+		ID              string
 		Name            string
 		State           string
 		RestartCount    int
@@ -91,7 +185,37 @@ func (s *Server) handleContainer() http.HandlerFunc {
 		HostnamePath    string
 		HostsPath       string
 		LogPath         string
+		LogWarnings     []string
 		AppArmorProfile string
+		ExitCode        int
+		Error           string
+		OOMKilled       bool
+		StartedAt       string
+		FinishedAt      string
+
+		Ports    []port
+		Mounts   []mount
+		Networks []endpoint
+		Env      []string
+		Labels   map[string]string
+
+		HealthStatus        string
+		HealthFailingStreak int
+		HealthProbes        []probe
+
+		CPUShares         int64
+		CPUPeriod         int64
+		CPUQuota          int64
+		Memory            int64
+		MemorySwap        int64
+		PidsLimit         int64
+		RestartPolicy     string
+		MaximumRetryCount int
+		RestartPolicies   []string
+		Audit             []AuditEntry
+
+		CollectorEnabled bool
+		CollectingLogs   bool
 
 		TopTitles    []string
 		TopProcesses [][]string
@@ -115,17 +239,96 @@ func (s *Server) handleContainer() http.HandlerFunc {
 		}
 
 		response := &containerResponse{
+			ID:              container.ID,
 			Name:            container.Name[1:],
 			State:           container.State.Status,
 			RestartCount:    container.RestartCount,
 			Created:         container.Created,
-			Command:         fmt.Sprintf("%s %s", container.Path, strings.Join(container.Args, "")),
+			Command:         strings.Join(append([]string{container.Path}, container.Args...), " "),
 			ImageID:         container.Image,
 			ResolvConfPath:  container.ResolvConfPath,
 			HostnamePath:    container.HostnamePath,
 			HostsPath:       container.HostsPath,
 			LogPath:         container.LogPath,
 			AppArmorProfile: container.AppArmorProfile,
+			RestartPolicies: restartPolicies,
+			Audit:           s.audit.list(container.ID),
+			LogWarnings:     logFileWarnings(container, s.logsMaxSize),
+		}
+		if s.collector != nil {
+			response.CollectorEnabled = true
+			response.CollectingLogs = s.collector.selected(container.ID, response.Name)
+		}
+		if container.State != nil {
+			response.ExitCode = container.State.ExitCode
+			response.Error = container.State.Error
+			response.OOMKilled = container.State.OOMKilled
+			response.StartedAt = container.State.StartedAt
+			response.FinishedAt = container.State.FinishedAt
+			if health := container.State.Health; health != nil {
+				response.HealthStatus = health.Status
+				response.HealthFailingStreak = health.FailingStreak
+				for index := len(health.Log) - 1; index >= 0; index-- {
+					result := health.Log[index]
+					response.HealthProbes = append(response.HealthProbes, probe{
+						Start:    result.Start,
+						End:      result.End,
+						ExitCode: result.ExitCode,
+						Output:   result.Output,
+					})
+				}
+			}
+		}
+		if container.Config != nil {
+			response.Env = maskEnv(container.Config.Env)
+			response.Labels = container.Config.Labels
+		}
+		if container.NetworkSettings != nil {
+			for containerPort, bindings := range container.NetworkSettings.Ports {
+				p := port{Container: string(containerPort)}
+				for _, binding := range bindings {
+					p.Host = append(p.Host, net.JoinHostPort(binding.HostIP, binding.HostPort))
+				}
+				response.Ports = append(response.Ports, p)
+			}
+			sort.Slice(response.Ports, func(i, j int) bool { return response.Ports[i].Container < response.Ports[j].Container })
+			for name, settings := range container.NetworkSettings.Networks {
+				if settings == nil {
+					continue
+				}
+				response.Networks = append(response.Networks, endpoint{
+					Network:     name,
+					IPAddress:   settings.IPAddress,
+					IPv6Address: settings.GlobalIPv6Address,
+					Gateway:     settings.Gateway,
+					MacAddress:  settings.MacAddress,
+					Aliases:     settings.Aliases,
+				})
+			}
+			sort.Slice(response.Networks, func(i, j int) bool { return response.Networks[i].Network < response.Networks[j].Network })
+		}
+		for _, m := range container.Mounts {
+			source := m.Source
+			if m.Type == "volume" && m.Name != "" {
+				source = m.Name
+			}
+			response.Mounts = append(response.Mounts, mount{
+				Type:        string(m.Type),
+				Source:      source,
+				Destination: m.Destination,
+				Mode:        m.Mode,
+				RW:          m.RW,
+			})
+		}
+		if container.HostConfig != nil {
+			response.CPUShares = container.HostConfig.CPUShares
+			response.CPUPeriod = container.HostConfig.CPUPeriod
+			response.CPUQuota = container.HostConfig.CPUQuota
+			response.Memory = container.HostConfig.Memory
+			response.MemorySwap = container.HostConfig.MemorySwap
+			response.PidsLimit = container.HostConfig.PidsLimit
+			response.RestartPolicy = container.HostConfig.RestartPolicy.Name
+			response.MaximumRetryCount = container.HostConfig.RestartPolicy.MaximumRetryCount
 		}
 
 		if container.State.Status == "running" {
@@ -144,5 +347,181 @@ func (s *Server) handleContainer() http.HandlerFunc {
 			logrus.Error(err)
 		}
 	}
+}
+
+// secretPattern matches environment variable names likely to hold a secret
+var secretPattern = regexp.MustCompile(`(?i)(pass|secret|token|key|credential|auth|private)`)
+
+// maskEnv hides the values of the environment variables that look like secrets.
+func maskEnv(env []string) []string {
+	masked := make([]string, len(env))
+	for index, variable := range env {
+		masked[index] = variable
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) == 2 && parts[1] != "" && secretPattern.MatchString(parts[0]) {
+			masked[index] = parts[0] + "=********"
+		}
+	}
+	return masked
+}
+
+// parseUpdateConfig reads the resources form of the container page.
+// Memory values accept units such as 512m, -1 disables the swap limit.
+func parseUpdateConfig(form url.Values) (update container.UpdateConfig, err error) {
+	integers := map[string]*int64{
+		"cpu_shares": &update.CPUShares,
+		"cpu_period": &update.CPUPeriod,
+		"cpu_quota":  &update.CPUQuota,
+		"pids_limit": &update.PidsLimit,
+	}
+	for name, field := range integers {
+		if value := form.Get(name); value != "" {
+			*field, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return update, fmt.Errorf("invalid %s value %q", name, value)
+			}
+		}
+	}
+	// A zero quota would be ignored by the daemon, -1 removes the quota
+	if form.Get("cpu_quota") == "0" {
+		update.CPUQuota = -1
+	}
+	sizes := map[string]*int64{
+		"memory":      &update.Memory,
+		"memory_swap": &update.MemorySwap,
+	}
+	for name, field := range sizes {
+		switch value := form.Get(name); value {
+		case "":
+		case "-1":
+			*field = -1
+		default:
+			*field, err = units.RAMInBytes(value)
+			if err != nil {
+				return update, fmt.Errorf("invalid %s value %q", name, value)
+			}
+		}
+	}
+	update.RestartPolicy, err = parseRestartPolicy(form.Get("restart"), form.Get("max_retries"))
+	return update, err
+}
+
+// validateUpdateConfig checks the limits the daemon would otherwise reject
+// with a less helpful message.
+func validateUpdateConfig(update container.UpdateConfig) error {
+	switch {
+	case update.CPUShares < 0 || update.CPUShares == 1:
+		return errors.New("CPU shares must be 0 or at least 2")
+	case update.CPUPeriod != 0 && (update.CPUPeriod < 1000 || update.CPUPeriod > 1000000):
+		return errors.New("CPU period must be between 1000 and 1000000 microseconds")
+	case update.CPUQuota < -1 || (update.CPUQuota > 0 && update.CPUQuota < 1000):
+		return errors.New("CPU quota must be -1 or at least 1000 microseconds")
+	case update.Memory < 0 || (update.Memory > 0 && update.Memory < minimumMemory):
+		return fmt.Errorf("memory limit must be at least %s", units.BytesSize(minimumMemory))
+	case update.MemorySwap < -1:
+		return errors.New("memory and swap limit must be -1 or positive")
+	case update.MemorySwap > 0 && update.Memory > 0 && update.MemorySwap < update.Memory:
+		return errors.New("memory and swap limit must be greater than the memory limit")
+	case update.PidsLimit < -1:
+		return errors.New("PIDs limit must be -1 or positive")
+	}
+	return nil
+}
+
+// diffUpdateConfig lists the fields of update that differ from the current
+// host configuration. Zero values are left untouched by the daemon.
+func diffUpdateConfig(current *container.HostConfig, update container.UpdateConfig) (changes []AuditChange) {
+	limits := []struct {
+		field    string
+		from, to int64
+		// unset is set for the limits where 0 and -1 both mean no limit
+		unset bool
+	}{
+		{"CPU shares", current.CPUShares, update.CPUShares, false},
+		{"CPU period", current.CPUPeriod, update.CPUPeriod, false},
+		{"CPU quota", current.CPUQuota, update.CPUQuota, true},
+		{"Memory", current.Memory, update.Memory, false},
+		{"Memory and swap", current.MemorySwap, update.MemorySwap, false},
+		{"PIDs limit", current.PidsLimit, update.PidsLimit, true},
+	}
+	for _, limit := range limits {
+		if limit.to == 0 || limit.from == limit.to || (limit.unset && limit.from <= 0 && limit.to <= 0) {
+			continue
+		}
+		changes = append(changes, AuditChange{
+			Field: limit.field,
+			From:  strconv.FormatInt(limit.from, 10),
+			To:    strconv.FormatInt(limit.to, 10),
+		})
+	}
+	// Containers created without a restart policy have an empty one, same as no
+	from, to := normalizeRestartPolicy(current.RestartPolicy), normalizeRestartPolicy(update.RestartPolicy)
+	if update.RestartPolicy.Name != "" && from != to {
+		changes = append(changes, AuditChange{
+			Field: "Restart policy",
+			From:  formatRestartPolicy(from),
+			To:    formatRestartPolicy(to),
+		})
+	}
+	return changes
+}
 
+// normalizeRestartPolicy names the empty restart policy "no".
+func normalizeRestartPolicy(policy container.RestartPolicy) container.RestartPolicy {
+	if policy.Name == "" {
+		policy.Name = "no"
+	}
+	return policy
+}
+
+func formatRestartPolicy(policy container.RestartPolicy) string {
+	if policy.Name == "" {
+		return "no"
+	}
+	if policy.IsOnFailure() && policy.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", policy.Name, policy.MaximumRetryCount)
+	}
+	return policy.Name
+}
+
+func (s *Server) handleContainerUpdate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		update, err := parseUpdateConfig(r.PostForm)
+		if err == nil {
+			err = validateUpdateConfig(update)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		containerID := mux.Vars(r)["id"]
+		current, err := s.docker.ContainerInspect(ctx, containerID)
+		if err != nil && err != context.Canceled {
+			logrus.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		changes := diffUpdateConfig(current.HostConfig, update)
+		if len(changes) > 0 {
+			body, err := s.docker.ContainerUpdate(ctx, containerID, update)
+			if err != nil && err != context.Canceled {
+				logrus.Error("Docker container update", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, warning := range body.Warnings {
+				logrus.Warn(warning)
+			}
+			s.audit.record(current.ID, changes)
+		}
+		http.Redirect(w, r, "/containers/"+containerID, http.StatusSeeOther)
+	}
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestDiffUpdateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		current container.HostConfig
+		update  container.UpdateConfig
+		want    []AuditChange
+	}{
+		{name: "nothing changed"},
+		{
+			name:    "zero values left untouched",
+			current: container.HostConfig{Resources: container.Resources{CPUShares: 512, Memory: 1 << 30}},
+		},
+		{
+			name:    "limits changed",
+			current: container.HostConfig{Resources: container.Resources{CPUShares: 512, Memory: 1 << 30}},
+			update:  container.UpdateConfig{Resources: container.Resources{CPUShares: 1024, Memory: 1 << 30, MemorySwap: -1}},
+			want: []AuditChange{
+				{Field: "CPU shares", From: "512", To: "1024"},
+				{Field: "Memory and swap", From: "0", To: "-1"},
+			},
+		},
+		{
+			name:    "unset CPU quota and PIDs limit",
+			current: container.HostConfig{Resources: container.Resources{CPUQuota: 0, PidsLimit: 0}},
+			update:  container.UpdateConfig{Resources: container.Resources{CPUQuota: -1, PidsLimit: -1}},
+		},
+		{
+			name:    "CPU quota and PIDs limit removed",
+			current: container.HostConfig{Resources: container.Resources{CPUQuota: 50000, PidsLimit: 100}},
+			update:  container.UpdateConfig{Resources: container.Resources{CPUQuota: -1, PidsLimit: -1}},
+			want: []AuditChange{
+				{Field: "CPU quota", From: "50000", To: "-1"},
+				{Field: "PIDs limit", From: "100", To: "-1"},
+			},
+		},
+		{
+			name:   "empty restart policy is no",
+			update: container.UpdateConfig{RestartPolicy: container.RestartPolicy{Name: "no"}},
+		},
+		{
+			name:    "restart policy changed",
+			current: container.HostConfig{RestartPolicy: container.RestartPolicy{Name: "always"}},
+			update:  container.UpdateConfig{RestartPolicy: container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}},
+			want:    []AuditChange{{Field: "Restart policy", From: "always", To: "on-failure:3"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := diffUpdateConfig(&test.current, test.update); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
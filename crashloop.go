@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	crashLoopAlert = "crash_loop"
+	oomKillAlert   = "oom_kill"
+)
+
+// ContainerAlert summarises the crash loop and OOM kill state of a container
+type ContainerAlert struct {
+	ID          string
+	Name        string
+	Restarts    int
+	RestartRate float64
+	CrashLoop   bool
+	OOMKilled   bool
+	LastOOM     time.Time
+}
+
+// crashAlert is the payload sent to the alerts webhook
+type crashAlert struct {
+	Alert       string    `json:"alert"`
+	ContainerID string    `json:"container_id"`
+	Name        string    `json:"name"`
+	Restarts    int       `json:"restarts"`
+	Window      string    `json:"window"`
+	Time        time.Time `json:"time"`
+}
+
+// crashDetector counts container deaths over a sliding window to flag
+// crash loops, and remembers the containers killed by the OOM killer.
+type crashDetector struct {
+	mu        sync.Mutex
+	window    time.Duration
+	threshold int
+	webhook   string
+	names     map[string]string
+	deaths    map[string][]time.Time
+	// lastDeaths are the latest die events, the clean exits are only counted
+	// once the container restarts and the deaths of manual stops are undone
+	lastDeaths map[string]death
+	ooms       map[string]time.Time
+	alerted    map[string]time.Time
+}
+
+// death is a die event of a container
+type death struct {
+	at      time.Time
+	counted bool
+}
+
+// validateCrashLoop checks the crash loop window and threshold flags.
+func validateCrashLoop(window time.Duration, threshold int) error {
+	if window <= 0 {
+		return fmt.Errorf("crash loop window must be positive, got %s", window)
+	}
+	if threshold <= 0 {
+		return fmt.Errorf("crash loop threshold must be at least 1, got %d", threshold)
+	}
+	return nil
+}
+
+func newCrashDetector(window time.Duration, threshold int, webhook string) *crashDetector {
+	return &crashDetector{
+		window:     window,
+		threshold:  threshold,
+		webhook:    webhook,
+		names:      make(map[string]string),
+		deaths:     make(map[string][]time.Time),
+		lastDeaths: make(map[string]death),
+		ooms:       make(map[string]time.Time),
+		alerted:    make(map[string]time.Time),
+	}
+}
+
+// prune drops the deaths older than the window, the lock must be held.
+func (c *crashDetector) prune(containerID string, now time.Time) []time.Time {
+	deaths := c.deaths[containerID]
+	index := sort.Search(len(deaths), func(i int) bool { return now.Sub(deaths[i]) <= c.window })
+	deaths = deaths[index:]
+	if len(deaths) == 0 {
+		delete(c.deaths, containerID)
+	} else {
+		c.deaths[containerID] = deaths
+	}
+	return deaths
+}
+
+func (c *crashDetector) observe(msg events.Message) {
+	if msg.Type != events.ContainerEventType {
+		return
+	}
+	at := time.Unix(0, msg.TimeNano)
+	containerID := msg.Actor.ID
+
+	c.mu.Lock()
+	if name, ok := msg.Actor.Attributes["name"]; ok {
+		c.names[containerID] = name
+	}
+	var alerts []crashAlert
+	switch msg.Action {
+	case "die":
+		// A clean exit is only part of a loop when the container restarts
+		if msg.Actor.Attributes["exitCode"] == "0" {
+			c.lastDeaths[containerID] = death{at: at}
+			break
+		}
+		c.lastDeaths[containerID] = death{at: at, counted: true}
+		alerts = append(alerts, c.count(containerID, at)...)
+	case "start", "restart":
+		last, ok := c.lastDeaths[containerID]
+		if ok && !last.counted {
+			alerts = append(alerts, c.count(containerID, last.at)...)
+		}
+		delete(c.lastDeaths, containerID)
+		// Restarting after a crash, such as the OOM kill itself, doesn't
+		// clear the OOM flag, starting again after a stop or a clean exit does
+		if msg.Action == "start" && !(ok && last.counted) {
+			delete(c.ooms, containerID)
+		}
+	case "stop":
+		// Stopped on purpose, the death that came before isn't a crash
+		if last, ok := c.lastDeaths[containerID]; ok && last.counted {
+			deaths := c.deaths[containerID]
+			if len(deaths) > 0 && deaths[len(deaths)-1].Equal(last.at) {
+				c.deaths[containerID] = deaths[:len(deaths)-1]
+				c.prune(containerID, at)
+			}
+		}
+		delete(c.lastDeaths, containerID)
+	case "oom":
+		c.ooms[containerID] = at
+		if at.Sub(c.alerted[containerID+oomKillAlert]) > c.window {
+			c.alerted[containerID+oomKillAlert] = at
+			alerts = append(alerts, c.alert(oomKillAlert, containerID, len(c.deaths[containerID]), at))
+		}
+	case "destroy":
+		delete(c.names, containerID)
+		delete(c.deaths, containerID)
+		delete(c.lastDeaths, containerID)
+		delete(c.ooms, containerID)
+		delete(c.alerted, containerID+crashLoopAlert)
+		delete(c.alerted, containerID+oomKillAlert)
+	}
+	c.mu.Unlock()
+
+	for _, alert := range alerts {
+		log.WithFields(log.Fields{
+			"container": alert.Name,
+			"restarts":  alert.Restarts,
+		}).Warn(alert.Alert)
+		if c.webhook == "" {
+			continue
+		}
+		go func(alert crashAlert) {
+			err := postWebhook(c.webhook, alert)
+			if err != nil {
+				log.Error("Alert webhook", err)
+			}
+		}(alert)
+	}
+}
+
+// count records a crash of a container and returns the crash loop alert
+// once the threshold is reached, at most once per window. The lock must be
+// held.
+func (c *crashDetector) count(containerID string, at time.Time) []crashAlert {
+	c.deaths[containerID] = append(c.deaths[containerID], at)
+	deaths := c.prune(containerID, at)
+	if len(deaths) < c.threshold || at.Sub(c.alerted[containerID+crashLoopAlert]) <= c.window {
+		return nil
+	}
+	c.alerted[containerID+crashLoopAlert] = at
+	return []crashAlert{c.alert(crashLoopAlert, containerID, len(deaths), at)}
+}
+
+// alert builds a webhook payload, the lock must be held.
+func (c *crashDetector) alert(kind, containerID string, restarts int, at time.Time) crashAlert {
+	return crashAlert{
+		Alert:       kind,
+		ContainerID: containerID,
+		Name:        c.names[containerID],
+		Restarts:    restarts,
+		Window:      c.window.String(),
+		Time:        at,
+	}
+}
+
+func (c *crashDetector) watch(messages <-chan events.Message) {
+	for msg := range messages {
+		c.observe(msg)
+	}
+}
+
+// status returns the alert state of a container.
+func (c *crashDetector) status(containerID string) ContainerAlert {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.statusLocked(containerID, time.Now())
+}
+
+func (c *crashDetector) statusLocked(containerID string, now time.Time) ContainerAlert {
+	restarts := len(c.prune(containerID, now))
+	lastOOM, oomKilled := c.ooms[containerID]
+	return ContainerAlert{
+		ID:          containerID,
+		Name:        c.names[containerID],
+		Restarts:    restarts,
+		RestartRate: float64(restarts) / c.window.Minutes(),
+		CrashLoop:   restarts >= c.threshold,
+		OOMKilled:   oomKilled,
+		LastOOM:     lastOOM,
+	}
+}
+
+// flagged returns the containers currently crash looping or OOM killed.
+func (c *crashDetector) flagged() []ContainerAlert {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	ids := make(map[string]struct{})
+	for id := range c.deaths {
+		ids[id] = struct{}{}
+	}
+	for id := range c.ooms {
+		ids[id] = struct{}{}
+	}
+	flagged := []ContainerAlert{}
+	for id := range ids {
+		status := c.statusLocked(id, now)
+		if status.CrashLoop || status.OOMKilled {
+			flagged = append(flagged, status)
+		}
+	}
+	sort.Slice(flagged, func(i, j int) bool { return flagged[i].Name < flagged[j].Name })
+	return flagged
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+func containerEvent(action, exitCode string, at time.Time) events.Message {
+	attributes := map[string]string{"name": "web"}
+	if exitCode != "" {
+		attributes["exitCode"] = exitCode
+	}
+	return events.Message{
+		Type:     events.ContainerEventType,
+		Action:   action,
+		Actor:    events.Actor{ID: "c1", Attributes: attributes},
+		TimeNano: at.UnixNano(),
+	}
+}
+
+func TestCrashDetector(t *testing.T) {
+	type event struct {
+		action   string
+		exitCode string
+	}
+	tests := []struct {
+		name      string
+		events    []event
+		restarts  int
+		crashLoop bool
+		oomKilled bool
+	}{
+		{
+			name:      "failed exits",
+			events:    []event{{"die", "1"}, {"start", ""}, {"die", "1"}, {"start", ""}, {"die", "2"}},
+			restarts:  3,
+			crashLoop: true,
+		},
+		{
+			name:   "clean exits without restart",
+			events: []event{{"die", "0"}, {"die", "0"}, {"die", "0"}},
+		},
+		{
+			name:      "clean exits restarted",
+			events:    []event{{"die", "0"}, {"start", ""}, {"die", "0"}, {"restart", ""}, {"die", "0"}, {"start", ""}},
+			restarts:  3,
+			crashLoop: true,
+		},
+		{
+			name:   "manual stops",
+			events: []event{{"kill", ""}, {"die", "143"}, {"stop", ""}, {"start", ""}, {"kill", ""}, {"die", "137"}, {"stop", ""}},
+		},
+		{
+			name:      "OOM kill restarted by the policy",
+			events:    []event{{"oom", ""}, {"die", "137"}, {"start", ""}},
+			restarts:  1,
+			oomKilled: true,
+		},
+		{
+			name:     "OOM kill then started again",
+			events:   []event{{"oom", ""}, {"die", "137"}, {"stop", ""}, {"start", ""}},
+			restarts: 0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			detector := newCrashDetector(time.Minute, 3, "")
+			at := time.Now().Add(-30 * time.Second)
+			for _, e := range test.events {
+				at = at.Add(time.Second)
+				detector.observe(containerEvent(e.action, e.exitCode, at))
+			}
+			status := detector.status("c1")
+			if status.Restarts != test.restarts || status.CrashLoop != test.crashLoop || status.OOMKilled != test.oomKilled {
+				t.Errorf("got restarts %d, crash loop %t, OOM killed %t, want %d, %t, %t",
+					status.Restarts, status.CrashLoop, status.OOMKilled, test.restarts, test.crashLoop, test.oomKilled)
+			}
+		})
+	}
+}
+
+func TestCrashDetectorAlertsOncePerWindow(t *testing.T) {
+	detector := newCrashDetector(time.Minute, 1, "")
+	at := time.Now()
+	alerts := 0
+	for i := 0; i < 5; i++ {
+		at = at.Add(time.Second)
+		detector.mu.Lock()
+		alerts += len(detector.count("c1", at))
+		detector.mu.Unlock()
+	}
+	if alerts != 1 {
+		t.Errorf("got %d crash loop alerts, want 1", alerts)
+	}
+}
+
+func TestValidateCrashLoop(t *testing.T) {
+	tests := []struct {
+		window    time.Duration
+		threshold int
+		err       bool
+	}{
+		{window: 5 * time.Minute, threshold: 5},
+		{window: time.Second, threshold: 1},
+		{window: 0, threshold: 5, err: true},
+		{window: -time.Minute, threshold: 5, err: true},
+		{window: time.Minute, threshold: 0, err: true},
+		{window: time.Minute, threshold: -1, err: true},
+	}
+	for _, test := range tests {
+		if err := validateCrashLoop(test.window, test.threshold); (err != nil) != test.err {
+			t.Errorf("window %s, threshold %d got error %v, want error %t", test.window, test.threshold, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	units "github.com/docker/go-units"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// diskUsageMaxAge is how long the disk usage, slow to compute on hosts
+	// with many images and volumes, is reused before being computed again
+	diskUsageMaxAge = time.Minute
+
+	treemapWidth  = 960
+	treemapHeight = 400
+
+	imagesCategory     = "Images"
+	containersCategory = "Containers"
+	volumesCategory    = "Local Volumes"
+	buildCacheCategory = "Build Cache"
+)
+
+// diskUsageSnapshot keeps the latest disk usage and when it was computed
+type diskUsageSnapshot struct {
+	mu    sync.Mutex
+	usage types.DiskUsage
+	at    time.Time
+}
+
+// diskUsage returns the disk usage and when it was computed. It is computed
+// again when older than diskUsageMaxAge or when refresh is set. When that
+// fails, the previous disk usage, if any, is returned with the error.
+func (s *Server) diskUsage(ctx context.Context, refresh bool) (types.DiskUsage, time.Time, error) {
+	// Concurrent requests wait for the same computation
+	s.diskUsageSnapshot.mu.Lock()
+	defer s.diskUsageSnapshot.mu.Unlock()
+	snapshot := &s.diskUsageSnapshot
+	if !refresh && !snapshot.at.IsZero() && time.Since(snapshot.at) < diskUsageMaxAge {
+		return snapshot.usage, snapshot.at, nil
+	}
+	usage, err := s.docker.DiskUsage(ctx)
+	if err != nil {
+		return snapshot.usage, snapshot.at, err
+	}
+	snapshot.usage, snapshot.at = usage, time.Now()
+	return snapshot.usage, snapshot.at, nil
+}
+
+// DiskCategory is a line of the disk usage summary, as docker system df
+type DiskCategory struct {
+	Type        string
+	Total       int
+	Active      int
+	Size        int64
+	Reclaimable int64
+}
+
+// ReclaimablePercent is the share of the size which can be reclaimed.
+func (c DiskCategory) ReclaimablePercent() int {
+	if c.Size <= 0 {
+		return 0
+	}
+	return int(100 * c.Reclaimable / c.Size)
+}
+
+// imageUniqueSize is the size of the layers of an image which no other
+// image shares.
+func imageUniqueSize(image *types.ImageSummary) int64 {
+	if image.SharedSize < 0 {
+		return image.Size
+	}
+	return image.Size - image.SharedSize
+}
+
+// diskCategories sums the disk usage per category the way docker system df
+// does: the images size counts shared layers once, unused images, stopped
+// containers, unused volumes and build cache records are reclaimable.
+func diskCategories(usage types.DiskUsage) []DiskCategory {
+	images := DiskCategory{Type: imagesCategory, Total: len(usage.Images), Size: usage.LayersSize}
+	var used int64
+	for _, image := range usage.Images {
+		if image.Containers > 0 {
+			images.Active++
+			used += imageUniqueSize(image)
+		}
+	}
+	images.Reclaimable = images.Size - used
+
+	containers := DiskCategory{Type: containersCategory, Total: len(usage.Containers)}
+	for _, container := range usage.Containers {
+		containers.Size += container.SizeRw
+		if container.State == "running" {
+			containers.Active++
+		} else {
+			containers.Reclaimable += container.SizeRw
+		}
+	}
+
+	volumes := DiskCategory{Type: volumesCategory, Total: len(usage.Volumes)}
+	for _, volume := range usage.Volumes {
+		// The usage of volumes of other drivers isn't known
+		if volume.UsageData == nil || volume.UsageData.Size < 0 {
+			continue
+		}
+		volumes.Size += volume.UsageData.Size
+		if volume.UsageData.RefCount > 0 {
+			volumes.Active++
+		} else {
+			volumes.Reclaimable += volume.UsageData.Size
+		}
+	}
+
+	buildCache := DiskCategory{Type: buildCacheCategory, Total: len(usage.BuildCache)}
+	for _, record := range usage.BuildCache {
+		if record.InUse {
+			buildCache.Active++
+		}
+		if record.Shared {
+			continue
+		}
+		buildCache.Size += record.Size
+		if !record.InUse {
+			buildCache.Reclaimable += record.Size
+		}
+	}
+	return []DiskCategory{images, containers, volumes, buildCache}
+}
+
+// treemapRect is a rectangle of the disk usage treemap
+type treemapRect struct {
+	X, Y, Width, Height float64
+	Category            string
+	Class               string
+	Label               string
+	URL                 string
+	Size                int64
+}
+
+// Title describes the rectangle on hover.
+func (r treemapRect) Title() string {
+	return r.Category + ": " + r.Label + " " + units.HumanSize(float64(r.Size))
+}
+
+// treemapObject is an object sized in the treemap
+type treemapObject struct {
+	Label string
+	URL   string
+	Size  int64
+}
+
+// newTreemap lays the objects of the categories out in columns as wide as
+// the category sizes, the objects of a column stacked by size.
+func newTreemap(categories []string, objects map[string][]treemapObject) []treemapRect {
+	var total int64
+	sizes := make(map[string]int64, len(categories))
+	for _, category := range categories {
+		for _, object := range objects[category] {
+			// Objects of unknown size are left out
+			if object.Size > 0 {
+				sizes[category] += object.Size
+			}
+		}
+		total += sizes[category]
+	}
+	var rects []treemapRect
+	if total <= 0 {
+		return rects
+	}
+	x := 0.0
+	for index, category := range categories {
+		if sizes[category] <= 0 {
+			continue
+		}
+		width := treemapWidth * float64(sizes[category]) / float64(total)
+		y := 0.0
+		for _, object := range objects[category] {
+			if object.Size <= 0 {
+				continue
+			}
+			height := treemapHeight * float64(object.Size) / float64(sizes[category])
+			rects = append(rects, treemapRect{
+				X:        x,
+				Y:        y,
+				Width:    width,
+				Height:   height,
+				Category: category,
+				Class:    "treemap-" + strconv.Itoa(index),
+				Label:    object.Label,
+				URL:      object.URL,
+				Size:     object.Size,
+			})
+			y += height
+		}
+		x += width
+	}
+	return rects
+}
+
+// handleDisk details the disk usage as docker system df -v, with a treemap
+// of the space taken by each object. When Docker fails to compute it, the
+// previous disk usage is shown with the error.
+func (s *Server) handleDisk() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	type image struct {
+		ID         string
+		Name       string
+		Created    time.Time
+		Size       int64
+		SharedSize int64
+		UniqueSize int64
+		Containers int64
+	}
+	type container struct {
+		ID     string
+		Name   string
+		Image  string
+		Status string
+		Size   int64
+	}
+	type volume struct {
+		Name  string
+		Links int64
+		Size  int64
+	}
+	type buildCache struct {
+		ID          string
+		Type        string
+		Description string
+		Size        int64
+		Created     time.Time
+		LastUsed    time.Time
+		UsageCount  int
+		InUse       bool
+		Shared      bool
+	}
+	type diskResponse struct {
+		Error      string
+		Computed   time.Time
+		Age        time.Duration
+		Stale      bool
+		Categories []DiskCategory
+		Treemap    []treemapRect
+		Width      int
+		Height     int
+		Images     []image
+		Containers []container
+		Volumes    []volume
+		BuildCache []buildCache
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		init.Do(func() {
+			tpl, err = s.parseTemplate("disk.html")
+		})
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		refresh, _ := strconv.ParseBool(r.URL.Query().Get("refresh"))
+		usage, computed, err := s.diskUsage(ctx, refresh)
+		response := diskResponse{
+			Computed:   computed,
+			Age:        time.Since(computed).Round(time.Second),
+			Stale:      time.Since(computed) >= diskUsageMaxAge/2,
+			Categories: diskCategories(usage),
+			Width:      treemapWidth,
+			Height:     treemapHeight,
+		}
+		if err != nil {
+			log.Error("Docker disk usage", err)
+			response.Error = err.Error()
+		}
+		objects := make(map[string][]treemapObject)
+		var uniqueSize int64
+		for _, img := range usage.Images {
+			name := "<none>"
+			if len(img.RepoTags) > 0 {
+				name = img.RepoTags[0]
+			}
+			response.Images = append(response.Images, image{
+				ID:         img.ID,
+				Name:       name,
+				Created:    time.Unix(img.Created, 0),
+				Size:       img.Size,
+				SharedSize: img.SharedSize,
+				UniqueSize: imageUniqueSize(img),
+				Containers: img.Containers,
+			})
+			uniqueSize += imageUniqueSize(img)
+			objects[imagesCategory] = append(objects[imagesCategory], treemapObject{Label: name, URL: "/images/" + img.ID, Size: imageUniqueSize(img)})
+		}
+		// The layers shared by several images are counted once
+		objects[imagesCategory] = append(objects[imagesCategory], treemapObject{Label: "shared layers", Size: usage.LayersSize - uniqueSize})
+		for _, c := range usage.Containers {
+			response.Containers = append(response.Containers, container{
+				ID:     c.ID,
+				Name:   c.Names[0][1:],
+				Image:  c.Image,
+				Status: c.Status,
+				Size:   c.SizeRw,
+			})
+			objects[containersCategory] = append(objects[containersCategory], treemapObject{Label: c.Names[0][1:], URL: "/containers/" + c.ID, Size: c.SizeRw})
+		}
+		for _, vol := range usage.Volumes {
+			// The snapshot is shared, the usage of other drivers is left unknown here
+			usageData := types.VolumeUsageData{Size: -1, RefCount: -1}
+			if vol.UsageData != nil {
+				usageData = *vol.UsageData
+			}
+			response.Volumes = append(response.Volumes, volume{Name: vol.Name, Links: usageData.RefCount, Size: usageData.Size})
+			if usageData.Size >= 0 {
+				objects[volumesCategory] = append(objects[volumesCategory], treemapObject{Label: vol.Name, URL: "/volumes/" + url.PathEscape(vol.Name), Size: usageData.Size})
+			}
+		}
+		for _, record := range usage.BuildCache {
+			entry := buildCache{
+				ID:          record.ID,
+				Type:        record.Type,
+				Description: record.Description,
+				Size:        record.Size,
+				Created:     record.CreatedAt,
+				UsageCount:  record.UsageCount,
+				InUse:       record.InUse,
+				Shared:      record.Shared,
+			}
+			if record.LastUsedAt != nil {
+				entry.LastUsed = *record.LastUsedAt
+			}
+			response.BuildCache = append(response.BuildCache, entry)
+			if !record.Shared {
+				objects[buildCacheCategory] = append(objects[buildCacheCategory], treemapObject{Label: record.Description, Size: record.Size})
+			}
+		}
+
+		sort.Slice(response.Images, func(i, j int) bool { return response.Images[i].UniqueSize > response.Images[j].UniqueSize })
+		sort.Slice(response.Containers, func(i, j int) bool { return response.Containers[i].Size > response.Containers[j].Size })
+		sort.Slice(response.Volumes, func(i, j int) bool { return response.Volumes[i].Size > response.Volumes[j].Size })
+		sort.Slice(response.BuildCache, func(i, j int) bool { return response.BuildCache[i].Size > response.BuildCache[j].Size })
+		for _, category := range objects {
+			sort.SliceStable(category, func(i, j int) bool { return category[i].Size > category[j].Size })
+		}
+		response.Treemap = newTreemap([]string{imagesCategory, containersCategory, volumesCategory, buildCacheCategory}, objects)
+
+		if err := tpl.ExecuteTemplate(w, "disk.html", response); err != nil {
+			log.Error(err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestDiskCategories(t *testing.T) {
+	usage := types.DiskUsage{
+		LayersSize: 1000,
+		Images: []*types.ImageSummary{
+			{ID: "used", Size: 600, SharedSize: 200, Containers: 1},
+			{ID: "unused", Size: 500, SharedSize: 200},
+			{ID: "unknown shared size", Size: 100, SharedSize: -1, Containers: 2},
+		},
+		Containers: []*types.Container{
+			{ID: "running", State: "running", SizeRw: 10},
+			{ID: "exited", State: "exited", SizeRw: 30},
+		},
+		Volumes: []*types.Volume{
+			{Name: "used", UsageData: &types.VolumeUsageData{Size: 50, RefCount: 1}},
+			{Name: "unused", UsageData: &types.VolumeUsageData{Size: 70}},
+			{Name: "other driver", UsageData: &types.VolumeUsageData{Size: -1, RefCount: -1}},
+			{Name: "no usage"},
+		},
+		BuildCache: []*types.BuildCache{
+			{ID: "in use", Size: 5, InUse: true},
+			{ID: "unused", Size: 7},
+			{ID: "shared", Size: 11, Shared: true},
+		},
+	}
+	want := []DiskCategory{
+		{Type: imagesCategory, Total: 3, Active: 2, Size: 1000, Reclaimable: 500},
+		{Type: containersCategory, Total: 2, Active: 1, Size: 40, Reclaimable: 30},
+		{Type: volumesCategory, Total: 4, Active: 1, Size: 120, Reclaimable: 70},
+		{Type: buildCacheCategory, Total: 3, Active: 1, Size: 12, Reclaimable: 7},
+	}
+	if got := diskCategories(usage); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := diskCategories(types.DiskUsage{}); len(got) != 4 || got[0].ReclaimablePercent() != 0 {
+		t.Errorf("got %+v for no usage", got)
+	}
+	if got := want[2].ReclaimablePercent(); got != 58 {
+		t.Errorf("got %d%% reclaimable, want 58%%", got)
+	}
+}
+
+func TestNewTreemap(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects map[string][]treemapObject
+		want    []treemapRect
+	}{
+		{name: "empty", objects: map[string][]treemapObject{}},
+		{
+			name: "columns and stacks",
+			objects: map[string][]treemapObject{
+				imagesCategory:     {{Label: "redis", Size: 300}, {Label: "nginx", Size: 100}},
+				volumesCategory:    {{Label: "data", Size: 400}, {Label: "unknown", Size: -1}},
+				containersCategory: {{Label: "empty", Size: 0}},
+			},
+			want: []treemapRect{
+				{X: 0, Y: 0, Width: 480, Height: 300, Category: imagesCategory, Class: "treemap-0", Label: "redis", Size: 300},
+				{X: 0, Y: 300, Width: 480, Height: 100, Category: imagesCategory, Class: "treemap-0", Label: "nginx", Size: 100},
+				{X: 480, Y: 0, Width: 480, Height: 400, Category: volumesCategory, Class: "treemap-2", Label: "data", Size: 400},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := newTreemap([]string{imagesCategory, containersCategory, volumesCategory, buildCacheCategory}, test.objects)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	healthStatusAction       = "health_status"
+	healthTransitionsLimit   = 100
+	defaultHealthProbesCount = 5
+)
+
+// HealthTransition is a container health status change seen by the console
+type HealthTransition struct {
+	Time time.Time
+	From string
+	To   string
+}
+
+// healthTracker keeps the health status transitions of the containers,
+// longer than the daemon which only keeps the last probes.
+type healthTracker struct {
+	mu          sync.Mutex
+	status      map[string]string
+	transitions map[string][]HealthTransition
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		status:      make(map[string]string),
+		transitions: make(map[string][]HealthTransition),
+	}
+}
+
+// record stores a status and returns true if it is a transition.
+func (h *healthTracker) record(containerID, status string, at time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	previous, known := h.status[containerID]
+	if known && previous == status {
+		return false
+	}
+	h.status[containerID] = status
+	transitions := append(h.transitions[containerID], HealthTransition{Time: at, From: previous, To: status})
+	if len(transitions) > healthTransitionsLimit {
+		transitions = transitions[len(transitions)-healthTransitionsLimit:]
+	}
+	h.transitions[containerID] = transitions
+	return true
+}
+
+func (h *healthTracker) forget(containerID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.status, containerID)
+	delete(h.transitions, containerID)
+}
+
+// history returns the transitions of a container, most recent first.
+func (h *healthTracker) history(containerID string) []HealthTransition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	transitions := h.transitions[containerID]
+	history := make([]HealthTransition, len(transitions))
+	for index := range transitions {
+		history[len(transitions)-1-index] = transitions[index]
+	}
+	return history
+}
+
+// watch records the health_status events until the subscription is closed.
+func (h *healthTracker) watch(messages <-chan events.Message) {
+	for msg := range messages {
+		if msg.Type != events.ContainerEventType {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(msg.Action, healthStatusAction+":"):
+			status := strings.TrimSpace(strings.TrimPrefix(msg.Action, healthStatusAction+":"))
+			h.record(msg.Actor.ID, status, time.Unix(0, msg.TimeNano))
+		case msg.Action == "destroy":
+			h.forget(msg.Actor.ID)
+		}
+	}
+}
+
+func (s *Server) handleHealth() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	type probe struct {
+		Start    time.Time
+		ExitCode int
+		Output   string
+	}
+	type container struct {
+		ID            string
+		Name          string
+		Status        string
+		StatusColor   string
+		FailingStreak int
+		Probes        []probe
+		Transitions   []HealthTransition
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		init.Do(func() {
+			tpl, err = s.parseTemplate("health.html")
+		})
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		probesCount := defaultHealthProbesCount
+		if value := r.URL.Query().Get("probes"); value != "" {
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 0 {
+				http.Error(w, "Invalid probes count", http.StatusBadRequest)
+				return
+			}
+			probesCount = count
+		}
+
+		containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{
+			All: true,
+			Filters: filters.NewArgs(
+				filters.Arg("health", types.Starting),
+				filters.Arg("health", types.Healthy),
+				filters.Arg("health", types.Unhealthy),
+			),
+		})
+		if err != nil && err != context.Canceled {
+			logrus.Error("Docker containers list", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := []container{}
+		for _, c := range containers {
+			inspect, err := s.docker.ContainerInspect(ctx, c.ID)
+			if err != nil {
+				logrus.Error("Docker container inspect", err)
+				continue
+			}
+			if inspect.State == nil || inspect.State.Health == nil {
+				continue
+			}
+			health := inspect.State.Health
+			s.health.record(c.ID, health.Status, time.Now())
+			item := container{
+				ID:            c.ID,
+				Name:          c.Names[0][1:],
+				Status:        health.Status,
+				FailingStreak: health.FailingStreak,
+				Transitions:   s.health.history(c.ID),
+			}
+			switch health.Status {
+			case types.Healthy:
+				item.StatusColor = "green"
+			case types.Starting:
+				item.StatusColor = "yellow"
+			default:
+				item.StatusColor = "red"
+			}
+			for index := len(health.Log) - 1; index >= 0 && len(item.Probes) < probesCount; index-- {
+				result := health.Log[index]
+				item.Probes = append(item.Probes, probe{
+					Start:    result.Start,
+					ExitCode: result.ExitCode,
+					Output:   result.Output,
+				})
+			}
+			response = append(response, item)
+		}
+
+		// Unhealthy containers first, then by name
+		sort.Slice(response, func(i, j int) bool {
+			if (response[i].Status == types.Unhealthy) != (response[j].Status == types.Unhealthy) {
+				return response[i].Status == types.Unhealthy
+			}
+			return response[i].Name < response[j].Name
+		})
+
+		err = tpl.ExecuteTemplate(w, "health.html", response)
+		if err != nil {
+			logrus.Error(err)
+		}
+	}
+}
@@ -2,9 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"html/template"
 	"net/http"
-	"sort"
 	"strings"
 	"sync"
 
@@ -15,18 +15,56 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// imageSortKeys are the sort keys of the images list
+var imageSortKeys = []string{"name", "created", "size"}
+
+// imageRow is a row of the images list
+type imageRow struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Tags    []string `json:"tags"`
+	Created int      `json:"created"`
+	Size    int      `json:"size"`
+}
+
+// listImages returns the rows of the images matching the query filters,
+// unsorted.
+func (s *Server) listImages(ctx context.Context, query listQuery) ([]imageRow, error) {
+	args := filters.NewArgs()
+	if query.Dangling != "" {
+		args.Add("dangling", query.Dangling)
+	}
+	for _, label := range query.Labels {
+		args.Add("label", label)
+	}
+	images, err := s.docker.ImageList(ctx, types.ImageListOptions{Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]imageRow, len(images))
+	for index, img := range images {
+		rows[index] = imageRow{
+			ID:      img.ID,
+			Name:    "None",
+			Created: int(img.Created),
+			Size:    int(img.Size),
+		}
+
+		if len(img.RepoTags) > 0 {
+			rows[index].Name = img.RepoTags[0]
+			rows[index].Tags = img.RepoTags[1:]
+		}
+	}
+	return rows, nil
+}
+
 func (s *Server) handleImages() http.HandlerFunc {
 	var (
 		init sync.Once
 		tpl  *template.Template
 		err  error
 	)
-	type image struct {
-		ID      string
-		Name    string
-		Created int
-		Size    int
-	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		init.Do(func() {
 			tpl, err = s.parseTemplate("images.html")
@@ -36,31 +74,34 @@ func (s *Server) handleImages() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		images, err := s.docker.ImageList(context.Background(), types.ImageListOptions{})
+		query, err := parseListQuery(r, imageSortKeys, "name", ascendingOrder)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		imagesResponse, err := s.listImages(context.Background(), query)
 		if err != nil {
 			logrus.Error("Docker images list", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		imagesResponse := make([]image, len(images))
-		for index, img := range images {
-			imagesResponse[index] = image{
-				ID:      img.ID,
-				Name:    "None",
-				Created: int(img.Created),
-				Size:    int(img.Size),
-			}
+		query.sort(imagesResponse, map[string]func(i, j int) bool{
+			"name":    func(i, j int) bool { return imagesResponse[i].Name < imagesResponse[j].Name },
+			"created": func(i, j int) bool { return imagesResponse[i].Created < imagesResponse[j].Created },
+			"size":    func(i, j int) bool { return imagesResponse[i].Size < imagesResponse[j].Size },
+		})
+		start, end, page := query.paginate(len(imagesResponse))
+		page.Items = imagesResponse[start:end]
 
-			if len(img.RepoTags) > 0 {
-				imagesResponse[index].Name = img.RepoTags[0]
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(page); err != nil {
+				logrus.Error(err)
 			}
+			return
 		}
-
-		sort.Slice(imagesResponse, func(i, j int) bool { return imagesResponse[i].Name < imagesResponse[j].Name })
-
-		err = tpl.ExecuteTemplate(w, "images.html", imagesResponse)
-		if err != nil {
+		if err := tpl.ExecuteTemplate(w, "images.html", page); err != nil {
 			logrus.Error(err)
 		}
 	}
@@ -73,6 +114,7 @@ func (s *Server) handleImage() http.HandlerFunc {
 		err  error
 	)
 	type imageResponse struct {
+		ID                 string
 		Name               string
 		Parent             string
 		Comment            string
@@ -111,6 +153,7 @@ func (s *Server) handleImage() http.HandlerFunc {
 		}
 
 		response := imageResponse{
+			ID:               image.ID,
 			Name:             strings.Join(image.RepoTags, ""),
 			Parent:           image.Parent,
 			Comment:          image.Comment,
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	log "github.com/sirupsen/logrus"
+)
+
+// listEventsDebounce groups bursts of events, such as a compose up, in a
+// single refresh of the rows
+const listEventsDebounce = 250 * time.Millisecond
+
+// listRow is a row of a list page rendered by the server
+type listRow struct {
+	ID   string `json:"id"`
+	HTML string `json:"html,omitempty"`
+}
+
+// shortRowID is the ID of a row in the rendered parameter of the list
+// events, short for a page of rows to fit in the URL.
+func shortRowID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// parseRenderedRows reads the comma separated short IDs of the rows a page
+// was rendered with.
+func parseRenderedRows(value string) map[string]bool {
+	rows := make(map[string]bool)
+	for _, id := range strings.Split(value, ",") {
+		if id != "" {
+			rows[id] = true
+		}
+	}
+	return rows
+}
+
+// containerRows returns the rows of the containers list by ID.
+func (s *Server) containerRows(ctx context.Context, query listQuery) (map[string]interface{}, error) {
+	containers, err := s.listContainers(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	rows := make(map[string]interface{}, len(containers))
+	for _, container := range containers {
+		rows[container.ID] = container
+	}
+	return rows, nil
+}
+
+// imageRows returns the rows of the images list by ID.
+func (s *Server) imageRows(ctx context.Context, query listQuery) (map[string]interface{}, error) {
+	images, err := s.listImages(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	rows := make(map[string]interface{}, len(images))
+	for _, image := range images {
+		rows[image.ID] = image
+	}
+	return rows, nil
+}
+
+// handleListEvents keeps a list page up to date. On the Docker events of
+// eventType, the rows matching the page filters are rendered again with the
+// rowTemplate of the page, and sent as server sent events: "row" for the
+// changed rows, "add" for the new ones and "remove" for the removed ones.
+// The rendered parameter lists the short IDs of the rows of the page, they
+// are sent again on connection not to miss the changes since the page was
+// rendered.
+func (s *Server) handleListEvents(page, rowTemplate string, sortKeys []string, eventType string, rows func(context.Context, listQuery) (map[string]interface{}, error)) http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		init.Do(func() {
+			tpl, err = s.parseTemplate(page)
+		})
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		query, err := parseListQuery(r, sortKeys, "", ascendingOrder)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Rows are sent unsorted, the container sizes only listed to sort
+		// by size aren't worth computing on every event
+		query.Sort = ""
+		f, ok := w.(http.Flusher)
+		if !ok {
+			log.Error("Streaming unsupported!")
+			http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+			return
+		}
+
+		messages := s.broker.subscribe()
+		defer s.broker.unsubscribe(messages)
+
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+		send := func(change string, row listRow) {
+			data, err := json.Marshal(row)
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			fmt.Fprint(w, NewEvent(change, string(data)))
+		}
+		// onPage holds the rows the page was rendered with, until connected
+		onPage := parseRenderedRows(r.URL.Query().Get("rendered"))
+		rendered := make(map[string]string)
+		// refresh renders the rows again, the rows of the other pages are
+		// only known on connection
+		refresh := func(connected bool) error {
+			current, err := rows(ctx, query)
+			if err != nil {
+				return err
+			}
+			for id, row := range current {
+				var html bytes.Buffer
+				if err := tpl.ExecuteTemplate(&html, rowTemplate, row); err != nil {
+					return err
+				}
+				previous, known := rendered[id]
+				if known && previous == html.String() {
+					continue
+				}
+				rendered[id] = html.String()
+				switch {
+				case known || onPage[shortRowID(id)]:
+					delete(onPage, shortRowID(id))
+					send("row", listRow{ID: id, HTML: html.String()})
+				case connected:
+					send("add", listRow{ID: id, HTML: html.String()})
+				}
+			}
+			for id := range rendered {
+				if _, ok := current[id]; !ok {
+					delete(rendered, id)
+					send("remove", listRow{ID: id})
+				}
+			}
+			// The rows of the page removed before the connection
+			for id := range onPage {
+				delete(onPage, id)
+				send("remove", listRow{ID: id})
+			}
+			f.Flush()
+			return nil
+		}
+		if err := refresh(false); err != nil {
+			log.Error(err)
+			return
+		}
+
+		var pending <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				if msg.Type == eventType && pending == nil {
+					pending = time.After(listEventsDebounce)
+				}
+			case <-pending:
+				pending = nil
+				if err := refresh(true); err != nil && ctx.Err() == nil {
+					log.Error(err)
+				}
+			}
+		}
+	}
+}
+
+// handleContainersEvents keeps the containers list up to date.
+func (s *Server) handleContainersEvents() http.HandlerFunc {
+	return s.handleListEvents("containers.html", "container-row", containerSortKeys, events.ContainerEventType, s.containerRows)
+}
+
+// handleImagesEvents keeps the images list up to date.
+func (s *Server) handleImagesEvents() http.HandlerFunc {
+	return s.handleListEvents("images.html", "image-row", imageSortKeys, events.ImageEventType, s.imageRows)
+}
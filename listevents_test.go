@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShortRowID(t *testing.T) {
+	tests := map[string]string{
+		"4c3f2a1b0d9e8f7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a":        "4c3f2a1b0d9e",
+		"sha256:9e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f": "9e1f2a3b4c5d",
+		"4c3f": "4c3f",
+	}
+	for id, want := range tests {
+		if got := shortRowID(id); got != want {
+			t.Errorf("%s got %s, want %s", id, got, want)
+		}
+	}
+}
+
+func TestParseRenderedRows(t *testing.T) {
+	want := map[string]bool{"4c3f2a1b0d9e": true, "9e1f2a3b4c5d": true}
+	if got := parseRenderedRows("4c3f2a1b0d9e,,9e1f2a3b4c5d"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := parseRenderedRows(""); len(got) != 0 {
+		t.Errorf("got %v for no rows", got)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+
+	ascendingOrder  = "asc"
+	descendingOrder = "desc"
+)
+
+// containerStates are the states accepted by the containers status filter
+var containerStates = map[string]bool{
+	"created":    true,
+	"restarting": true,
+	"running":    true,
+	"removing":   true,
+	"paused":     true,
+	"exited":     true,
+	"dead":       true,
+}
+
+// listQuery holds the sort, filters and page of a list page read from the
+// query string
+type listQuery struct {
+	Sort     string
+	Order    string
+	State    string
+	Labels   []string
+	Dangling string
+	Driver   string
+	Page     int
+	PerPage  int
+
+	path   string
+	values url.Values
+}
+
+// parseListQuery reads the list options, sort being one of sortKeys.
+func parseListQuery(r *http.Request, sortKeys []string, defaultSort, defaultOrder string) (listQuery, error) {
+	query := r.URL.Query()
+	options := listQuery{
+		Sort:     defaultSort,
+		Order:    defaultOrder,
+		State:    query.Get("state"),
+		Dangling: query.Get("dangling"),
+		Driver:   query.Get("driver"),
+		Page:     1,
+		PerPage:  defaultPerPage,
+		path:     r.URL.Path,
+		values:   query,
+	}
+	for _, label := range query["label"] {
+		// Forms send the empty label input
+		if label != "" {
+			options.Labels = append(options.Labels, label)
+		}
+	}
+	if sortKey := query.Get("sort"); sortKey != "" {
+		valid := false
+		for _, key := range sortKeys {
+			valid = valid || key == sortKey
+		}
+		if !valid {
+			return options, fmt.Errorf("invalid sort %q, expected one of %s", sortKey, strings.Join(sortKeys, ", "))
+		}
+		options.Sort = sortKey
+	}
+	if order := query.Get("order"); order != "" {
+		if order != ascendingOrder && order != descendingOrder {
+			return options, fmt.Errorf("invalid order %q, expected asc or desc", order)
+		}
+		options.Order = order
+	}
+	if options.State != "" && !containerStates[options.State] {
+		return options, fmt.Errorf("invalid state %q", options.State)
+	}
+	if options.Dangling != "" {
+		dangling, err := strconv.ParseBool(options.Dangling)
+		if err != nil {
+			return options, fmt.Errorf("invalid dangling value %q", options.Dangling)
+		}
+		options.Dangling = strconv.FormatBool(dangling)
+	}
+	for name, field := range map[string]*int{"page": &options.Page, "per_page": &options.PerPage} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 {
+				return options, fmt.Errorf("invalid %s value %q", name, value)
+			}
+			*field = parsed
+		}
+	}
+	if options.PerPage > maxPerPage {
+		options.PerPage = maxPerPage
+	}
+	return options, nil
+}
+
+// matchLabels tells whether labels have every key or key=value of the
+// label filter.
+func (q listQuery) matchLabels(labels map[string]string) bool {
+	for _, filter := range q.Labels {
+		parts := strings.SplitN(filter, "=", 2)
+		value, ok := labels[parts[0]]
+		if !ok || (len(parts) == 2 && value != parts[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sort sorts items with the less function of the sort key, reversed for
+// the descending order.
+func (q listQuery) sort(items interface{}, less map[string]func(i, j int) bool) {
+	compare := less[q.Sort]
+	sort.SliceStable(items, func(i, j int) bool {
+		if q.Order == descendingOrder {
+			return compare(j, i)
+		}
+		return compare(i, j)
+	})
+}
+
+// url returns the list page with the current options changed by changes.
+func (q listQuery) url(changes map[string]string) string {
+	values := url.Values{}
+	for key, value := range q.values {
+		values[key] = value
+	}
+	for key, value := range changes {
+		if value == "" {
+			values.Del(key)
+			continue
+		}
+		values.Set(key, value)
+	}
+	if len(values) == 0 {
+		return q.path
+	}
+	return q.path + "?" + values.Encode()
+}
+
+// SortURL sorts the list by key, toggling the order when already sorted by key.
+func (q listQuery) SortURL(key string) string {
+	order := ascendingOrder
+	if q.Sort == key && q.Order == ascendingOrder {
+		order = descendingOrder
+	}
+	return q.url(map[string]string{"sort": key, "order": order, "page": ""})
+}
+
+// SortIndicator marks the column the list is sorted by.
+func (q listQuery) SortIndicator(key string) string {
+	switch {
+	case q.Sort != key:
+		return ""
+	case q.Order == descendingOrder:
+		return "▼"
+	}
+	return "▲"
+}
+
+// listPage is a page of a list page, as rendered or returned as JSON
+type listPage struct {
+	Items   interface{} `json:"items"`
+	Total   int         `json:"total"`
+	Page    int         `json:"page"`
+	Pages   int         `json:"pages"`
+	PerPage int         `json:"per_page"`
+	Query   listQuery   `json:"-"`
+}
+
+// PreviousURL is the previous page, if any.
+func (p listPage) PreviousURL() string {
+	if p.Page <= 1 {
+		return ""
+	}
+	return p.Query.url(map[string]string{"page": strconv.Itoa(p.Page - 1)})
+}
+
+// NextURL is the next page, if any.
+func (p listPage) NextURL() string {
+	if p.Page >= p.Pages {
+		return ""
+	}
+	return p.Query.url(map[string]string{"page": strconv.Itoa(p.Page + 1)})
+}
+
+// paginate returns the bounds of the current page among total items and
+// the page description.
+func (q listQuery) paginate(total int) (start, end int, page listPage) {
+	page = listPage{Total: total, Page: q.Page, PerPage: q.PerPage, Query: q}
+	page.Pages = (total + q.PerPage - 1) / q.PerPage
+	start = (q.Page - 1) * q.PerPage
+	if start > total {
+		start = total
+	}
+	end = start + q.PerPage
+	if end > total {
+		end = total
+	}
+	return start, end, page
+}
+
+// wantsJSON tells whether the client asked for a JSON response.
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
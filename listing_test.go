@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseListQuery(t *testing.T) {
+	sortKeys := []string{"name", "created", "size"}
+	tests := []struct {
+		url  string
+		want listQuery
+		err  bool
+	}{
+		{
+			url:  "/containers",
+			want: listQuery{Sort: "name", Order: ascendingOrder, Page: 1, PerPage: defaultPerPage},
+		},
+		{
+			url:  "/containers?sort=size&order=desc&page=3&per_page=20",
+			want: listQuery{Sort: "size", Order: descendingOrder, Page: 3, PerPage: 20},
+		},
+		{
+			url:  "/containers?state=exited&label=env%3Dprod&label=&label=team",
+			want: listQuery{Sort: "name", Order: ascendingOrder, State: "exited", Labels: []string{"env=prod", "team"}, Page: 1, PerPage: defaultPerPage},
+		},
+		{
+			url:  "/images?dangling=1&driver=local",
+			want: listQuery{Sort: "name", Order: ascendingOrder, Dangling: "true", Driver: "local", Page: 1, PerPage: defaultPerPage},
+		},
+		{
+			url:  "/containers?per_page=100000",
+			want: listQuery{Sort: "name", Order: ascendingOrder, Page: 1, PerPage: maxPerPage},
+		},
+		{url: "/containers?sort=id", err: true},
+		{url: "/containers?order=up", err: true},
+		{url: "/containers?state=sleeping", err: true},
+		{url: "/containers?dangling=maybe", err: true},
+		{url: "/containers?page=0", err: true},
+		{url: "/containers?per_page=ten", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			got, err := parseListQuery(httptest.NewRequest("GET", test.url, nil), sortKeys, "name", ascendingOrder)
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v, want error %t", err, test.err)
+			}
+			if test.err {
+				return
+			}
+			got.path, got.values = "", nil
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestListQueryURLs(t *testing.T) {
+	query, err := parseListQuery(httptest.NewRequest("GET", "/images?sort=name&page=2&per_page=10", nil), []string{"name", "size"}, "", ascendingOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := query.SortURL("name"), "/images?order=desc&per_page=10&sort=name"; got != want {
+		t.Errorf("sort URL got %s, want %s", got, want)
+	}
+	if got, want := query.SortURL("size"), "/images?order=asc&per_page=10&sort=size"; got != want {
+		t.Errorf("sort URL got %s, want %s", got, want)
+	}
+	start, end, page := query.paginate(25)
+	if start != 10 || end != 20 || page.Pages != 3 {
+		t.Errorf("got items %d to %d of %d pages, want 10 to 20 of 3", start, end, page.Pages)
+	}
+	if got, want := page.PreviousURL(), "/images?page=1&per_page=10&sort=name"; got != want {
+		t.Errorf("previous URL got %s, want %s", got, want)
+	}
+	if got, want := page.NextURL(), "/images?page=3&per_page=10&sort=name"; got != want {
+		t.Errorf("next URL got %s, want %s", got, want)
+	}
+	if _, _, last := query.paginate(20); last.NextURL() != "" {
+		t.Errorf("last page has a next URL %s", last.NextURL())
+	}
+}
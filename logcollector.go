@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/docker/docker/api/types/events"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	logsIndexName          = "logs"
+	logDocumentType        = "log"
+	logsBatchSize          = 500
+	logsBatchInterval      = time.Second
+	logsRetentionInterval  = 5 * time.Minute
+	logsRetentionBatchSize = 1000
+	allContainersSelector  = "*"
+	// logsSizeKey stores the summed length of the indexed messages
+	logsSizeKey = "logs_size"
+)
+
+// logDocument is a log line as stored in the logs index
+type logDocument struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Container   string    `json:"container"`
+	ContainerID string    `json:"container_id"`
+	Stream      string    `json:"stream"`
+	Level       string    `json:"level"`
+	Message     string    `json:"message"`
+}
+
+// Type implements bleve.Classifier
+func (d logDocument) Type() string {
+	return logDocumentType
+}
+
+func newLogsIndexMapping() mapping.IndexMapping {
+	keywordField := bleve.NewTextFieldMapping()
+	keywordField.Analyzer = keyword.Name
+
+	document := bleve.NewDocumentMapping()
+	document.AddFieldMappingsAt("timestamp", bleve.NewDateTimeFieldMapping())
+	document.AddFieldMappingsAt("container", keywordField)
+	document.AddFieldMappingsAt("container_id", keywordField)
+	document.AddFieldMappingsAt("stream", keywordField)
+	document.AddFieldMappingsAt("level", keywordField)
+	document.AddFieldMappingsAt("message", bleve.NewTextFieldMapping())
+
+	indexMapping := bleve.NewIndexMapping()
+	indexMapping.AddDocumentMapping(logDocumentType, document)
+	indexMapping.DefaultField = "message"
+	return indexMapping
+}
+
+// openLogsIndex opens the logs index kept in the user cache directory,
+// creating it on first use.
+func openLogsIndex() (bleve.Index, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	os.Mkdir(filepath.Join(cacheDir, applicationName), os.ModePerm)
+	indexPath := filepath.Join(cacheDir, applicationName, "logs.index")
+	index, err := bleve.Open(indexPath)
+	if err == bleve.ErrorIndexPathDoesNotExist {
+		index, err = bleve.New(indexPath, newLogsIndexMapping())
+	}
+	if err != nil {
+		return nil, err
+	}
+	index.SetName(logsIndexName)
+	return index, nil
+}
+
+// logCollector tails the logs of the selected containers into the logs index
+type logCollector struct {
+	ctx            context.Context
+	index          bleve.Index
+	tailer         *logTailer
+	retention      time.Duration
+	retentionLines uint64
+	retentionSize  int64
+
+	// size is the summed length of the indexed messages, kept in the index
+	sizeMu sync.Mutex
+	size   int64
+
+	mu        sync.Mutex
+	selectors map[string]bool
+	// excluded are the containers deselected while collecting all of them
+	excluded map[string]bool
+	lines    chan logDocument
+}
+
+func newLogCollector(ctx context.Context, s *Server, selectors []string, retention time.Duration, retentionLines uint64, retentionSize int64) (*logCollector, error) {
+	index, err := openLogsIndex()
+	if err != nil {
+		return nil, err
+	}
+	c := &logCollector{
+		ctx:            ctx,
+		index:          index,
+		retention:      retention,
+		retentionLines: retentionLines,
+		retentionSize:  retentionSize,
+		selectors:      make(map[string]bool),
+		excluded:       make(map[string]bool),
+		lines:          make(chan logDocument, logsBatchSize),
+	}
+	if size, _ := index.GetInternal([]byte(logsSizeKey)); size != nil {
+		c.size, _ = strconv.ParseInt(string(size), 10, 64)
+	}
+	for _, selector := range selectors {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			c.selectors[selector] = true
+		}
+	}
+	c.tailer = newLogTailer(ctx, s, func(container tailedContainer) bool {
+		return c.selected(container.ID, container.Name)
+	}, c.collect)
+	c.tailer.since = func(container tailedContainer) string {
+		if last := c.lastIndexed(container.ID); !last.IsZero() {
+			return formatLogsTimestamp(last.Add(time.Nanosecond))
+		}
+		return ""
+	}
+	return c, nil
+}
+
+// selected reports whether the logs of a container are collected.
+func (c *logCollector) selected(containerID, name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.selectors[containerID] || c.selectors[name] {
+		return true
+	}
+	return c.selectors[allContainersSelector] && !c.excluded[containerID]
+}
+
+// run indexes the logs of the selected containers until the collector
+// context is done.
+func (c *logCollector) run(messages <-chan events.Message) {
+	go c.indexLines()
+	go c.enforceRetention()
+	c.tailer.run(messages)
+}
+
+// setSelected adds or removes a container from the collection.
+func (c *logCollector) setSelected(containerID, name string, selected bool) {
+	c.setSelectors(containerID, name, selected)
+	if selected {
+		c.tailer.tail(tailedContainer{ID: containerID, Name: name})
+	} else {
+		c.tailer.stop(containerID)
+	}
+}
+
+// setSelectors updates the selectors, a container deselected while all
+// containers are collected is excluded.
+func (c *logCollector) setSelectors(containerID, name string, selected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if selected {
+		c.selectors[containerID] = true
+		delete(c.excluded, containerID)
+	} else {
+		delete(c.selectors, containerID)
+		delete(c.selectors, name)
+		if c.selectors[allContainersSelector] {
+			c.excluded[containerID] = true
+		}
+	}
+}
+
+// collect queues a line for indexing.
+func (c *logCollector) collect(ctx context.Context, container tailedContainer, line logLine) {
+	document := logDocument{
+		Timestamp:   line.Time,
+		Container:   container.Name,
+		ContainerID: container.ID,
+		Stream:      line.Stream,
+		Level:       detectLogLevel(line).String(),
+		Message:     stripANSI(line.Text),
+	}
+	select {
+	case c.lines <- document:
+	case <-ctx.Done():
+	}
+}
+
+// lastIndexed returns the timestamp of the latest indexed line of a
+// container, read from the document ID as stored dates lose nanoseconds.
+func (c *logCollector) lastIndexed(containerID string) time.Time {
+	byContainer := bleve.NewTermQuery(containerID)
+	byContainer.SetField("container_id")
+	request := bleve.NewSearchRequestOptions(byContainer, 1, 0, false)
+	request.SortBy([]string{"-timestamp", "-_id"})
+	result, err := c.index.Search(request)
+	if err != nil || len(result.Hits) == 0 {
+		return time.Time{}
+	}
+	return logDocumentTime(result.Hits[0].ID)
+}
+
+// logDocumentID identifies a line by container, timestamp and sequence.
+// The timestamp is zero padded for the IDs to sort by time, lines without
+// a timestamp, before the Unix epoch, are given 0.
+func logDocumentID(document logDocument, sequence int) string {
+	var nanoseconds int64
+	if document.Timestamp.After(time.Unix(0, 0)) {
+		nanoseconds = document.Timestamp.UnixNano()
+	}
+	return fmt.Sprintf("%s-%019d-%d", document.ContainerID, nanoseconds, sequence)
+}
+
+func logDocumentTime(id string) time.Time {
+	parts := strings.Split(id, "-")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	nanoseconds, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || nanoseconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanoseconds)
+}
+
+// indexLines indexes the collected lines in batches.
+func (c *logCollector) indexLines() {
+	ticker := time.NewTicker(logsBatchInterval)
+	defer ticker.Stop()
+	batch := c.index.NewBatch()
+	var size int64
+	flush := func() {
+		if batch.Size() == 0 {
+			return
+		}
+		if err := c.commit(batch, size); err != nil {
+			log.Error("Logs index", err)
+		}
+		batch.Reset()
+		size = 0
+	}
+	sequence := 0
+	for {
+		select {
+		case document := <-c.lines:
+			sequence++
+			if err := batch.Index(logDocumentID(document, sequence), document); err != nil {
+				log.Error("Logs index", err)
+			} else {
+				size += int64(len(document.Message))
+			}
+			if batch.Size() >= logsBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-c.ctx.Done():
+			flush()
+			return
+		}
+	}
+}
+
+// commit runs a batch changing the summed length of the indexed messages by
+// delta, the new length is stored along.
+func (c *logCollector) commit(batch *bleve.Batch, delta int64) error {
+	c.sizeMu.Lock()
+	defer c.sizeMu.Unlock()
+	size := c.size + delta
+	if size < 0 {
+		size = 0
+	}
+	batch.SetInternal([]byte(logsSizeKey), []byte(strconv.FormatInt(size, 10)))
+	if err := c.index.Batch(batch); err != nil {
+		return err
+	}
+	c.size = size
+	return nil
+}
+
+// indexedSize returns the summed length of the indexed messages.
+func (c *logCollector) indexedSize() int64 {
+	c.sizeMu.Lock()
+	defer c.sizeMu.Unlock()
+	return c.size
+}
+
+// enforceRetention periodically deletes the lines older than the retention
+// period, and the oldest lines beyond the retention lines count or once the
+// messages add up to more than the retention size.
+func (c *logCollector) enforceRetention() {
+	ticker := time.NewTicker(logsRetentionInterval)
+	defer ticker.Stop()
+	for {
+		if c.retention > 0 {
+			expired := bleve.NewDateRangeQuery(time.Time{}, time.Now().Add(-c.retention))
+			expired.SetField("timestamp")
+			for c.deleteOldest(expired, logsRetentionBatchSize) > 0 {
+			}
+		}
+		if c.retentionLines > 0 {
+			count, err := c.index.DocCount()
+			for err == nil && count > c.retentionLines {
+				excess := int(count - c.retentionLines)
+				if excess > logsRetentionBatchSize {
+					excess = logsRetentionBatchSize
+				}
+				if c.deleteOldest(bleve.NewMatchAllQuery(), excess) == 0 {
+					break
+				}
+				count, err = c.index.DocCount()
+			}
+		}
+		for c.retentionSize > 0 {
+			size := c.indexedSize()
+			count, err := c.index.DocCount()
+			if err != nil || count == 0 || size <= c.retentionSize {
+				break
+			}
+			// As many lines as the excess takes at the average message length
+			excess := int((size-c.retentionSize)*int64(count)/size) + 1
+			if excess > logsRetentionBatchSize {
+				excess = logsRetentionBatchSize
+			}
+			if c.deleteOldest(bleve.NewMatchAllQuery(), excess) == 0 {
+				break
+			}
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteOldest deletes up to size of the oldest lines matching query and
+// returns how many were deleted.
+func (c *logCollector) deleteOldest(q query.Query, size int) int {
+	request := bleve.NewSearchRequestOptions(q, size, 0, false)
+	request.SortBy([]string{"timestamp"})
+	request.Fields = []string{"message"}
+	result, err := c.index.Search(request)
+	if err != nil {
+		log.Error("Logs retention", err)
+		return 0
+	}
+	batch := c.index.NewBatch()
+	var deleted int64
+	for _, hit := range result.Hits {
+		batch.Delete(hit.ID)
+		if message, ok := hit.Fields["message"].(string); ok {
+			deleted += int64(len(message))
+		}
+	}
+	if err := c.commit(batch, -deleted); err != nil {
+		log.Error("Logs retention", err)
+		return 0
+	}
+	return len(result.Hits)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve"
+)
+
+func TestLogDocumentID(t *testing.T) {
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		want      string
+		time      time.Time
+	}{
+		{
+			name:      "timestamp",
+			timestamp: time.Unix(1500000000, 123),
+			want:      "c1-1500000000000000123-2",
+			time:      time.Unix(1500000000, 123),
+		},
+		{
+			name:      "zero padded",
+			timestamp: time.Unix(1, 0),
+			want:      "c1-0000000001000000000-2",
+			time:      time.Unix(1, 0),
+		},
+		{name: "no timestamp", timestamp: time.Time{}, want: "c1-0000000000000000000-2"},
+		{name: "before the epoch", timestamp: time.Unix(-10, 0), want: "c1-0000000000000000000-2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id := logDocumentID(logDocument{ContainerID: "c1", Timestamp: test.timestamp}, 2)
+			if id != test.want {
+				t.Errorf("got ID %q, want %q", id, test.want)
+			}
+			if got := logDocumentTime(id); !got.Equal(test.time) {
+				t.Errorf("got time %s, want %s", got, test.time)
+			}
+		})
+	}
+}
+
+func TestLogCollectorSelected(t *testing.T) {
+	c := &logCollector{
+		selectors: map[string]bool{allContainersSelector: true},
+		excluded:  make(map[string]bool),
+	}
+	if !c.selected("c1", "web") {
+		t.Fatal("* doesn't select the container")
+	}
+	c.setSelectors("c1", "web", false)
+	if c.selected("c1", "web") {
+		t.Error("deselected container still selected with *")
+	}
+	if !c.selected("c2", "db") {
+		t.Error("other containers no longer selected with *")
+	}
+	c.setSelectors("c1", "web", true)
+	if !c.selected("c1", "web") {
+		t.Error("container selected again isn't selected")
+	}
+}
+
+func TestLogCollectorRetentionSize(t *testing.T) {
+	index, err := bleve.NewMemOnly(newLogsIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	// A cancelled collector enforces the retention once
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := &logCollector{ctx: ctx, index: index, retentionSize: 25}
+
+	at := time.Unix(1541413800, 0)
+	batch := index.NewBatch()
+	for sequence := 0; sequence < 5; sequence++ {
+		document := logDocument{Timestamp: at.Add(time.Duration(sequence) * time.Second), ContainerID: "c1", Message: "0123456789"}
+		if err := batch.Index(logDocumentID(document, sequence), document); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.commit(batch, 50); err != nil {
+		t.Fatal(err)
+	}
+	c.enforceRetention()
+
+	if count, _ := index.DocCount(); count != 2 {
+		t.Errorf("got %d lines, want the 2 newest", count)
+	}
+	if last := c.lastIndexed("c1"); !last.Equal(at.Add(4 * time.Second)) {
+		t.Errorf("got last line at %s, want %s", last, at.Add(4*time.Second))
+	}
+	if size, _ := index.GetInternal([]byte(logsSizeKey)); c.indexedSize() != 20 || string(size) != "20" {
+		t.Errorf("got size %d, stored %q, want 20", c.indexedSize(), size)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	textLogsFormat   = "text"
+	ndjsonLogsFormat = "ndjson"
+)
+
+// exportedLogLine is a line of an NDJSON export
+type exportedLogLine struct {
+	Container string                 `json:"container"`
+	Name      string                 `json:"name"`
+	Stream    string                 `json:"stream"`
+	Timestamp string                 `json:"timestamp,omitempty"`
+	Text      string                 `json:"text"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+func newExportedLogLine(prefix logsPrefix, line logLine) exportedLogLine {
+	exported := exportedLogLine{
+		Container: line.ContainerID,
+		Name:      prefix.name,
+		Stream:    line.Stream,
+		Text:      line.Text,
+		Fields:    line.Fields,
+	}
+	if !line.Time.IsZero() {
+		exported.Timestamp = line.Time.Format(time.RFC3339Nano)
+	}
+	return exported
+}
+
+// writeTextLogLine writes a line the way docker-compose logs prints it.
+func writeTextLogLine(w io.Writer, prefix logsPrefix, line logLine) error {
+	var err error
+	if line.Time.IsZero() {
+		_, err = fmt.Fprintf(w, "%s %s\n", prefix.label, line.Text)
+	} else {
+		_, err = fmt.Fprintf(w, "%s %s %s\n", prefix.label, line.Time.Format(time.RFC3339Nano), line.Text)
+	}
+	return err
+}
+
+// handleLogsDownload streams the logs of the selected containers as an
+// attachment, without following them. Filters of the log viewer apply.
+func (s *Server) handleLogsDownload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query, err := parseLogsQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Follow = false
+		if _, ok := r.URL.Query()["tail"]; !ok {
+			query.Tail = "all"
+		}
+
+		format := r.URL.Query().Get("format")
+		contentType, extension := "text/plain; charset=utf-8", ".log"
+		switch format {
+		case "", textLogsFormat:
+			format = textLogsFormat
+		case ndjsonLogsFormat:
+			contentType, extension = "application/x-ndjson", ".ndjson"
+		default:
+			http.Error(w, fmt.Sprintf("Unknown format %q", format), http.StatusBadRequest)
+			return
+		}
+		compress, _ := strconv.ParseBool(r.URL.Query().Get("gzip"))
+		ansi, err := parseANSIMode(query.ANSI, ansiKeep, ansiKeep, ansiStrip)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		containers, err := s.resolveLogsContainers(ctx, query.ContainersID)
+		if err != nil && err != context.Canceled {
+			log.Error("Docker containers list", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(containers) == 0 {
+			http.Error(w, "No container", http.StatusNotFound)
+			return
+		}
+		containersID := make([]string, len(containers))
+		for index, container := range containers {
+			containersID[index] = container.ID
+		}
+		prefixes := logsPrefixes(containers)
+
+		filename := "logs-" + time.Now().Format("20060102-150405") + extension
+		if len(containers) == 1 {
+			filename = prefixes[containersID[0]].name + "-" + filename
+		}
+		var output io.Writer = w
+		if compress {
+			filename += ".gz"
+			contentType = "application/gzip"
+			gzipWriter := gzip.NewWriter(w)
+			defer gzipWriter.Close()
+			output = gzipWriter
+		}
+		buffered := bufio.NewWriter(output)
+		defer buffered.Flush()
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+		lines := s.streamLogs(ctx, query.paged(containersID), query.containerLogsOptions)
+		if len(containersID) > 1 {
+			lines = mergeLogLines(ctx, lines, logsReorderWindow)
+		}
+		encoder := json.NewEncoder(buffered)
+		for line := range lines {
+			if !query.filter.match(line) {
+				continue
+			}
+			prefix := prefixes[line.ContainerID]
+			if ansi == ansiStrip {
+				line.Text = stripANSI(line.Text)
+			}
+			if format == ndjsonLogsFormat {
+				err = encoder.Encode(newExportedLogLine(prefix, line))
+			} else {
+				err = writeTextLogLine(buffered, prefix, line)
+			}
+			if err != nil {
+				log.Error("Logs download", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExportedLogLine(t *testing.T) {
+	prefix := logsPrefix{name: "web", label: "web_1 |", color: "cyan"}
+	line := logLine{
+		ContainerID: "c1",
+		Stream:      stderrStream,
+		Time:        time.Date(2018, 11, 5, 10, 30, 0, 500, time.UTC),
+		Text:        `{"level":"error"}`,
+		Fields:      map[string]interface{}{"level": "error"},
+	}
+	tests := []struct {
+		name string
+		line logLine
+		want string
+	}{
+		{
+			name: "JSON line",
+			line: line,
+			want: `{"container":"c1","name":"web","stream":"stderr","timestamp":"2018-11-05T10:30:00.0000005Z","text":"{\"level\":\"error\"}","fields":{"level":"error"}}`,
+		},
+		{
+			name: "text line without timestamp",
+			line: logLine{ContainerID: "c1", Stream: stdoutStream, Text: "started"},
+			want: `{"container":"c1","name":"web","stream":"stdout","text":"started"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := json.Marshal(newExportedLogLine(prefix, test.line))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
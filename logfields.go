@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseLogFields decodes a JSON object log line into its fields.
+func parseLogFields(text string) (map[string]interface{}, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "{") || !strings.HasSuffix(text, "}") {
+		return nil, false
+	}
+	// Numbers are kept as written, float64 would round large integers
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil || decoder.More() {
+		return nil, false
+	}
+	return fields, true
+}
+
+// lookupLogField returns the value of a field, either a top level key or a
+// dotted path through nested objects such as http.status.
+func lookupLogField(fields map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := fields[name]; ok {
+		return value, true
+	}
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	nested, ok := fields[parts[0]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookupLogField(nested, parts[1])
+}
+
+// formatLogField formats a field value for display and comparison.
+func formatLogField(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+	return fmt.Sprint(value)
+}
+
+// logFieldMatch is a name=value condition on a JSON log field
+type logFieldMatch struct {
+	name  string
+	value string
+}
+
+// parseLogFieldMatches parses space separated name=value conditions.
+func parseLogFieldMatches(value string) ([]logFieldMatch, error) {
+	var matches []logFieldMatch
+	for _, condition := range strings.Fields(value) {
+		parts := strings.SplitN(condition, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid field condition %q, expecting name=value", condition)
+		}
+		matches = append(matches, logFieldMatch{name: parts[0], value: parts[1]})
+	}
+	return matches, nil
+}
+
+func (m logFieldMatch) match(fields map[string]interface{}) bool {
+	value, ok := lookupLogField(fields, m.name)
+	return ok && formatLogField(value) == m.value
+}
+
+// parseLogColumns parses a comma separated list of field names.
+func parseLogColumns(value string) (columns []string) {
+	for _, column := range strings.Split(value, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// logEntry is a log line as sent to the browser
+type logEntry struct {
+	Container string                 `json:"container"`
+	Name      string                 `json:"name,omitempty"`
+	Color     string                 `json:"color,omitempty"`
+	Stream    string                 `json:"stream"`
+	Time      string                 `json:"time,omitempty"`
+	Text      string                 `json:"text"`
+	HTML      string                 `json:"html,omitempty"`
+	Rules     []string               `json:"rules,omitempty"`
+	Columns   []string               `json:"columns,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// newLogEntry builds the entry of a line, the values of the chosen columns
+// are extracted from the fields, the remaining fields are kept apart.
+func newLogEntry(line logLine, columns []string, timestamps bool) logEntry {
+	entry := logEntry{
+		Container: line.ContainerID,
+		Stream:    line.Stream,
+		Text:      line.Text,
+	}
+	if timestamps && !line.Time.IsZero() {
+		entry.Time = line.Time.Format(time.RFC3339Nano)
+	}
+	if line.Fields == nil {
+		return entry
+	}
+	entry.Fields = make(map[string]interface{}, len(line.Fields))
+	for name, value := range line.Fields {
+		entry.Fields[name] = value
+	}
+	if len(columns) > 0 {
+		entry.Columns = make([]string, len(columns))
+		for index, column := range columns {
+			value, _ := lookupLogField(line.Fields, column)
+			entry.Columns[index] = formatLogField(value)
+			delete(entry.Fields, column)
+		}
+	}
+	return entry
+}
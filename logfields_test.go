@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestLogFieldNumbers(t *testing.T) {
+	fields, ok := parseLogFields(`{"id":9007199254740993,"latency":0.000001,"size":1000000,"http":{"status":200,"bytes":12345678901}}`)
+	if !ok {
+		t.Fatal("JSON line not parsed")
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "id", want: "9007199254740993"},
+		{name: "latency", want: "0.000001"},
+		{name: "size", want: "1000000"},
+		{name: "http.status", want: "200"},
+		{name: "http", want: `{"bytes":12345678901,"status":200}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, _ := lookupLogField(fields, test.name)
+			if got := formatLogField(value); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			condition := logFieldMatch{name: test.name, value: test.want}
+			if !condition.match(fields) {
+				t.Errorf("%s=%s doesn't match", test.name, test.want)
+			}
+		})
+	}
+
+	if _, ok := parseLogFields(`{"a":1} {"b":2}`); ok {
+		t.Error("two JSON objects parsed as one line")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type logLevel int
+
+const (
+	levelUnknown logLevel = iota
+	levelTrace
+	levelDebug
+	levelInfo
+	levelWarning
+	levelError
+	levelFatal
+)
+
+var logLevelNames = map[string]logLevel{
+	"trace":     levelTrace,
+	"debug":     levelDebug,
+	"info":      levelInfo,
+	"notice":    levelInfo,
+	"warn":      levelWarning,
+	"warning":   levelWarning,
+	"error":     levelError,
+	"err":       levelError,
+	"fatal":     levelFatal,
+	"panic":     levelFatal,
+	"critical":  levelFatal,
+	"crit":      levelFatal,
+	"alert":     levelFatal,
+	"emerg":     levelFatal,
+	"emergency": levelFatal,
+}
+
+// syslogSeverities maps the syslog severities, from 0 to 7, to levels
+var syslogSeverities = []logLevel{levelFatal, levelFatal, levelFatal, levelError, levelWarning, levelInfo, levelInfo, levelDebug}
+
+var (
+	logfmtLevelPattern = regexp.MustCompile(`\b(?:level|lvl)="?([a-zA-Z]+)`)
+	syslogPattern      = regexp.MustCompile(`^<(\d{1,3})>`)
+	prefixLevelPattern = regexp.MustCompile(`^\W{0,2}(?i:(trace|debug|info|notice|warn|warning|error|err|fatal|panic|critical|crit))\b`)
+	jsonLevelFields    = []string{"level", "lvl", "severity", "log.level"}
+)
+
+func (l logLevel) String() string {
+	switch l {
+	case levelTrace:
+		return "trace"
+	case levelDebug:
+		return "debug"
+	case levelInfo:
+		return "info"
+	case levelWarning:
+		return "warning"
+	case levelError:
+		return "error"
+	case levelFatal:
+		return "fatal"
+	}
+	return ""
+}
+
+func parseLogLevel(name string) (logLevel, error) {
+	if name == "" {
+		return levelUnknown, nil
+	}
+	level, ok := logLevelNames[strings.ToLower(name)]
+	if !ok {
+		return levelUnknown, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// fieldsLogLevel returns the level of a JSON log line.
+func fieldsLogLevel(fields map[string]interface{}) logLevel {
+	for _, name := range jsonLevelFields {
+		if value, ok := lookupLogField(fields, name); ok {
+			if level, ok := logLevelNames[strings.ToLower(formatLogField(value))]; ok {
+				return level
+			}
+		}
+	}
+	return levelUnknown
+}
+
+// detectLogLevel guesses the level of a line written as JSON with a level
+// field, as logfmt (logrus text formatter), with a syslog priority prefix
+// or starting with the level name.
+func detectLogLevel(line logLine) logLevel {
+	if line.Fields != nil {
+		return fieldsLogLevel(line.Fields)
+	}
+	text := stripANSI(line.Text)
+	if match := syslogPattern.FindStringSubmatch(text); match != nil {
+		priority, _ := strconv.Atoi(match[1])
+		return syslogSeverities[priority%8]
+	}
+	if match := logfmtLevelPattern.FindStringSubmatch(text); match != nil {
+		return logLevelNames[strings.ToLower(match[1])]
+	}
+	if match := prefixLevelPattern.FindStringSubmatch(text); match != nil {
+		return logLevelNames[strings.ToLower(match[1])]
+	}
+	return levelUnknown
+}
+
+// logFilter selects the log lines sent to the browser
+type logFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+	stream  string
+	level   logLevel
+	fields  []logFieldMatch
+}
+
+func parseLogFilter(query url.Values) (filter logFilter, err error) {
+	if include := query.Get("include"); include != "" {
+		filter.include, err = regexp.Compile(include)
+		if err != nil {
+			return filter, fmt.Errorf("invalid include expression: %s", err)
+		}
+	}
+	if exclude := query.Get("exclude"); exclude != "" {
+		filter.exclude, err = regexp.Compile(exclude)
+		if err != nil {
+			return filter, fmt.Errorf("invalid exclude expression: %s", err)
+		}
+	}
+	switch stream := query.Get("stream"); stream {
+	case "", stdoutStream, stderrStream:
+		filter.stream = stream
+	default:
+		return filter, fmt.Errorf("unknown stream %q", stream)
+	}
+	filter.level, err = parseLogLevel(query.Get("level"))
+	if err != nil {
+		return filter, err
+	}
+	filter.fields, err = parseLogFieldMatches(query.Get("fields"))
+	return filter, err
+}
+
+// match reports whether line passes the filter. Lines with no detectable
+// level are kept by the level threshold, lines not written as JSON are
+// dropped by field conditions.
+func (f logFilter) match(line logLine) bool {
+	for _, field := range f.fields {
+		if line.Fields == nil || !field.match(line.Fields) {
+			return false
+		}
+	}
+	if f.stream != "" && line.Stream != f.stream {
+		return false
+	}
+	text := stripANSI(line.Text)
+	if f.include != nil && !f.include.MatchString(text) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(text) {
+		return false
+	}
+	if f.level != levelUnknown {
+		if level := detectLogLevel(line); level != levelUnknown && level < f.level {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestDetectLogLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want logLevel
+	}{
+		{name: "JSON level", text: `{"level":"warn","msg":"disk almost full"}`, want: levelWarning},
+		{name: "JSON severity", text: `{"severity":"ERROR","message":"failed"}`, want: levelError},
+		{name: "JSON without level", text: `{"msg":"error"}`, want: levelUnknown},
+		{name: "logfmt", text: `time="2018-11-05T10:30:00Z" level=error msg="failed"`, want: levelError},
+		{name: "logfmt quoted", text: `lvl="debug" msg="connected"`, want: levelDebug},
+		{name: "syslog priority", text: "<11>1 2018-11-05T10:30:00Z host app - - - failed", want: levelError},
+		{name: "bracketed", text: "[ERROR] failed", want: levelError},
+		{name: "bracketed lowercase", text: "[warn] disk almost full", want: levelWarning},
+		{name: "bare", text: "WARNING disk almost full", want: levelWarning},
+		{name: "bare with colon", text: "INFO: started", want: levelInfo},
+		{name: "colored", text: "\x1b[31mFATAL\x1b[0m out of memory", want: levelFatal},
+		{name: "error inside a word", text: "terror in the logs", want: levelUnknown},
+		{name: "error prefixing a word", text: "errors: 0", want: levelUnknown},
+		{name: "info prefixing a word", text: "information about the build", want: levelUnknown},
+		{name: "error not first", text: "request failed with error", want: levelUnknown},
+		{name: "level inside a word", text: "sealevel=info", want: levelUnknown},
+		{name: "no level", text: "listening on :8080", want: levelUnknown},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line := logLine{Text: test.text}
+			line.Fields, _ = parseLogFields(line.Text)
+			if got := detectLogLevel(line); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
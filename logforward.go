@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	forwardBufferSize       = 10000
+	forwardBatchSize        = 200
+	forwardBatchInterval    = time.Second
+	forwardMaxAttempts      = 5
+	forwardInitialBackoff   = 500 * time.Millisecond
+	forwardMaximumBackoff   = 30 * time.Second
+	forwardSelectorsParam   = "container"
+	forwardLabelsParam      = "label"
+	forwardProjectsParam    = "project"
+	forwardRedactedPassword = "xxxxx"
+)
+
+// ForwarderStats are the delivery counters of a log forwarder
+type ForwarderStats struct {
+	Received  uint64
+	Sent      uint64
+	Dropped   uint64
+	Failed    uint64
+	Retries   uint64
+	Buffered  int
+	Tailed    int
+	LastError string
+	LastSent  time.Time
+}
+
+// containerSelector matches containers by name, label or compose project.
+// An empty selector matches every container.
+type containerSelector struct {
+	names    map[string]bool
+	labels   map[string]string
+	projects map[string]bool
+}
+
+func newContainerSelector(names, labels, projects []string) containerSelector {
+	selector := containerSelector{
+		names:    make(map[string]bool),
+		labels:   make(map[string]string),
+		projects: make(map[string]bool),
+	}
+	for _, name := range names {
+		selector.names[name] = true
+	}
+	for _, label := range labels {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) == 1 {
+			parts = append(parts, "")
+		}
+		selector.labels[parts[0]] = parts[1]
+	}
+	for _, project := range projects {
+		selector.projects[project] = true
+	}
+	return selector
+}
+
+func (s containerSelector) match(container tailedContainer) bool {
+	if len(s.names) == 0 && len(s.labels) == 0 && len(s.projects) == 0 {
+		return true
+	}
+	if s.names[container.Name] || s.names[container.ID] || s.projects[container.Labels[composeProjectLabel]] {
+		return true
+	}
+	for key, value := range s.labels {
+		if actual, ok := container.Labels[key]; ok && (value == "" || actual == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// logForwarder ships the lines of the selected containers to a sink. Lines
+// are buffered, and dropped when the buffer is full so that a slow sink
+// never blocks the containers logs.
+type logForwarder struct {
+	Name     string
+	sink     logSink
+	selector containerSelector
+	tailer   *logTailer
+	buffer   chan forwardedLine
+	// backoff is the delay before the first retry of a batch
+	backoff time.Duration
+
+	mu    sync.Mutex
+	stats ForwarderStats
+}
+
+// newLogForwarder parses a forwarding address, the query string selects
+// the containers: ?container=name&label=key=value&project=name
+func newLogForwarder(ctx context.Context, s *Server, address string) (*logForwarder, error) {
+	target, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := newLogSink(target)
+	if err != nil {
+		return nil, err
+	}
+	query := target.Query()
+	if _, ok := target.User.Password(); ok {
+		target.User = url.UserPassword(target.User.Username(), forwardRedactedPassword)
+	}
+	f := &logForwarder{
+		Name:     target.String(),
+		sink:     sink,
+		selector: newContainerSelector(query[forwardSelectorsParam], query[forwardLabelsParam], query[forwardProjectsParam]),
+		buffer:   make(chan forwardedLine, forwardBufferSize),
+		backoff:  forwardInitialBackoff,
+	}
+	f.tailer = newLogTailer(ctx, s, f.selector.match, f.enqueue)
+	return f, nil
+}
+
+// enqueue buffers a line without blocking.
+func (f *logForwarder) enqueue(ctx context.Context, container tailedContainer, line logLine) {
+	forwarded := forwardedLine{
+		Time:        line.Time,
+		Container:   container.Name,
+		ContainerID: container.ID,
+		Stream:      line.Stream,
+		Level:       detectLogLevel(line).String(),
+		Message:     stripANSI(line.Text),
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stats.Received++
+	select {
+	case f.buffer <- forwarded:
+	default:
+		f.stats.Dropped++
+	}
+}
+
+func (f *logForwarder) run(ctx context.Context, messages <-chan events.Message) {
+	go f.deliver(ctx)
+	f.tailer.run(messages)
+}
+
+// deliver sends the buffered lines in batches until ctx is done.
+func (f *logForwarder) deliver(ctx context.Context) {
+	defer f.sink.close()
+	ticker := time.NewTicker(forwardBatchInterval)
+	defer ticker.Stop()
+	batch := make([]forwardedLine, 0, forwardBatchSize)
+	for {
+		select {
+		case line := <-f.buffer:
+			batch = append(batch, line)
+			if len(batch) < forwardBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-ctx.Done():
+			return
+		}
+		f.send(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+// send delivers a batch, retrying with an exponential backoff.
+func (f *logForwarder) send(ctx context.Context, batch []forwardedLine) {
+	backoff := f.backoff
+	for attempt := 1; ; attempt++ {
+		err := f.sink.send(batch)
+		f.mu.Lock()
+		if err == nil {
+			f.stats.Sent += uint64(len(batch))
+			f.stats.LastSent = time.Now()
+			f.mu.Unlock()
+			return
+		}
+		f.stats.LastError = err.Error()
+		if attempt == forwardMaxAttempts {
+			f.stats.Failed += uint64(len(batch))
+			f.mu.Unlock()
+			log.Errorf("Forwarding %d lines to %s failed: %s", len(batch), f.Name, err)
+			return
+		}
+		f.stats.Retries++
+		f.mu.Unlock()
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > forwardMaximumBackoff {
+			backoff = forwardMaximumBackoff
+		}
+	}
+}
+
+// Stats returns a snapshot of the forwarder counters.
+func (f *logForwarder) Stats() ForwarderStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stats := f.stats
+	stats.Buffered = len(f.buffer)
+	stats.Tailed = f.tailer.tailed()
+	return stats
+}
+
+func (s *Server) handleLogsForwarding() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	type forwarder struct {
+		Name  string
+		Stats ForwarderStats
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		init.Do(func() {
+			tpl, err = s.parseTemplate("forwarding.html")
+		})
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response := make([]forwarder, len(s.forwarders))
+		for index, f := range s.forwarders {
+			response[index] = forwarder{Name: f.Name, Stats: f.Stats()}
+		}
+		if err := tpl.ExecuteTemplate(w, "forwarding.html", response); err != nil {
+			log.Error(err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var forwardedTestLine = forwardedLine{
+	Time:        time.Date(2018, 11, 5, 10, 30, 0, 500, time.UTC),
+	Container:   "web",
+	ContainerID: "c1",
+	Stream:      stderrStream,
+	Level:       levelWarning.String(),
+	Message:     "disk almost full",
+}
+
+// readUDP returns the next datagram received on conn.
+func readUDP(t *testing.T, conn net.PacketConn) []byte {
+	t.Helper()
+	buffer := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer[:n]
+}
+
+// acceptTCP returns what the first connection to listener sends until it is
+// closed.
+func acceptTCP(t *testing.T, listener net.Listener) <-chan []byte {
+	t.Helper()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		data, _ := ioutil.ReadAll(conn)
+		received <- data
+	}()
+	return received
+}
+
+func newTestSink(t *testing.T, address string) logSink {
+	t.Helper()
+	target, err := url.Parse(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, err := newLogSink(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+func TestSyslogSinks(t *testing.T) {
+	message := fmt.Sprintf("<12>1 2018-11-05T10:30:00.0000005Z %s web - - - disk almost full", hostname)
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	sink := newTestSink(t, "syslog+udp://"+udp.LocalAddr().String())
+	if err := sink.send([]forwardedLine{forwardedTestLine}); err != nil {
+		t.Fatal(err)
+	}
+	sink.close()
+	if got := string(readUDP(t, udp)); got != message {
+		t.Errorf("UDP got %q, want %q", got, message)
+	}
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	received := acceptTCP(t, tcp)
+	sink = newTestSink(t, "syslog+tcp://"+tcp.Addr().String())
+	if err := sink.send([]forwardedLine{forwardedTestLine, forwardedTestLine}); err != nil {
+		t.Fatal(err)
+	}
+	sink.close()
+	// TCP messages are octet counted
+	framed := fmt.Sprintf("%d %s", len(message), message)
+	if got := string(<-received); got != framed+framed {
+		t.Errorf("TCP got %q, want %q", got, framed+framed)
+	}
+}
+
+func TestGELFSinks(t *testing.T) {
+	want := map[string]interface{}{
+		"version":         "1.1",
+		"host":            hostname,
+		"short_message":   "disk almost full",
+		"timestamp":       1541413800.0000005,
+		"level":           4.0,
+		"_container_name": "web",
+		"_container_id":   "c1",
+		"_stream":         stderrStream,
+	}
+	check := func(network string, payload []byte) {
+		t.Helper()
+		var got map[string]interface{}
+		if err := json.Unmarshal(payload, &got); err != nil {
+			t.Fatalf("%s payload %q: %s", network, payload, err)
+		}
+		for key, value := range want {
+			if got[key] != value {
+				t.Errorf("%s %s got %v, want %v", network, key, got[key], value)
+			}
+		}
+	}
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	sink := newTestSink(t, "gelf+udp://"+udp.LocalAddr().String())
+	if err := sink.send([]forwardedLine{forwardedTestLine}); err != nil {
+		t.Fatal(err)
+	}
+	sink.close()
+	check("UDP", readUDP(t, udp))
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	received := acceptTCP(t, tcp)
+	sink = newTestSink(t, "gelf+tcp://"+tcp.Addr().String())
+	if err := sink.send([]forwardedLine{forwardedTestLine, forwardedTestLine}); err != nil {
+		t.Fatal(err)
+	}
+	sink.close()
+	// TCP messages are null terminated
+	frames := bytes.Split(<-received, []byte{0})
+	if len(frames) != 3 || len(frames[2]) != 0 {
+		t.Fatalf("TCP got %d frames, want 2 null terminated frames", len(frames)-1)
+	}
+	check("TCP", frames[0])
+	check("TCP", frames[1])
+}
+
+func TestHTTPSink(t *testing.T) {
+	var lines []forwardedLine
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("got content type %q", r.Header.Get("Content-Type"))
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var line forwardedLine
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Error(err)
+			}
+			lines = append(lines, line)
+		}
+	}))
+	defer server.Close()
+
+	// The selectors of the forwarder address aren't sent
+	sink := newTestSink(t, server.URL+"/logs?container=web")
+	if err := sink.send([]forwardedLine{forwardedTestLine, forwardedTestLine}); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || !lines[0].Time.Equal(forwardedTestLine.Time) || lines[1].Message != forwardedTestLine.Message {
+		t.Errorf("got %+v, want the 2 lines", lines)
+	}
+}
+
+func TestLogForwarderRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		stats    ForwarderStats
+	}{
+		{name: "delivered", failures: 0, stats: ForwarderStats{Sent: 2}},
+		{name: "delivered after retries", failures: 2, stats: ForwarderStats{Sent: 2, Retries: 2}},
+		{name: "failed", failures: forwardMaxAttempts, stats: ForwarderStats{Failed: 2, Retries: forwardMaxAttempts - 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				attempts int
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if attempts++; attempts <= test.failures {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				}
+			}))
+			defer server.Close()
+			f := &logForwarder{Name: server.URL, sink: &httpSink{url: server.URL}, backoff: time.Millisecond}
+			f.send(context.Background(), []forwardedLine{forwardedTestLine, forwardedTestLine})
+			stats := f.stats
+			if stats.Sent != test.stats.Sent || stats.Failed != test.stats.Failed || stats.Retries != test.stats.Retries {
+				t.Errorf("got sent %d, failed %d, retries %d, want %d, %d, %d",
+					stats.Sent, stats.Failed, stats.Retries, test.stats.Sent, test.stats.Failed, test.stats.Retries)
+			}
+			if test.failures > 0 && !strings.Contains(stats.LastError, "503") {
+				t.Errorf("got last error %q", stats.LastError)
+			}
+		})
+	}
+}
+
+func TestLogForwarderDropsWhenFull(t *testing.T) {
+	f := &logForwarder{buffer: make(chan forwardedLine, 2)}
+	container := tailedContainer{ID: "c1", Name: "web"}
+	for i := 0; i < 5; i++ {
+		f.enqueue(context.Background(), container, logLine{Stream: stdoutStream, Text: "line"})
+	}
+	if f.stats.Received != 5 || f.stats.Dropped != 3 || len(f.buffer) != 2 {
+		t.Errorf("got received %d, dropped %d, buffered %d, want 5, 3, 2", f.stats.Received, f.stats.Dropped, len(f.buffer))
+	}
+}
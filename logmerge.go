@@ -0,0 +1,92 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"time"
+)
+
+const (
+	logsReorderWindow  = 500 * time.Millisecond
+	logsReorderMaxSize = 10000
+)
+
+// logsComposeColors are the container prefix colors, as used by docker-compose
+var logsComposeColors = []string{"cyan", "yellow", "green", "magenta", "blue", "red"}
+
+type pendingLogLine struct {
+	line    logLine
+	arrival time.Time
+}
+
+// logLinesHeap orders the pending lines by timestamp
+type logLinesHeap []pendingLogLine
+
+func (h logLinesHeap) Len() int            { return len(h) }
+func (h logLinesHeap) Less(i, j int) bool  { return h[i].line.Time.Before(h[j].line.Time) }
+func (h logLinesHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *logLinesHeap) Push(x interface{}) { *h = append(*h, x.(pendingLogLine)) }
+func (h *logLinesHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// mergeLogLines reorders lines coming from several containers by timestamp.
+// Each line is held for window after its arrival so that older lines of the
+// other containers, arriving slightly later, can be sent before it.
+func mergeLogLines(ctx context.Context, lines <-chan logLine, window time.Duration) <-chan logLine {
+	merged := make(chan logLine)
+	go func() {
+		defer close(merged)
+		pending := &logLinesHeap{}
+		ticker := time.NewTicker(window / 5)
+		defer ticker.Stop()
+
+		send := func(line logLine) bool {
+			select {
+			case merged <- line:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		// flush sends the lines held for longer than window, or all of them
+		flush := func(all bool) bool {
+			now := time.Now()
+			for pending.Len() > 0 {
+				head := (*pending)[0]
+				if !all && now.Sub(head.arrival) < window && pending.Len() <= logsReorderMaxSize {
+					return true
+				}
+				heap.Pop(pending)
+				if !send(head.line) {
+					return false
+				}
+			}
+			return true
+		}
+
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					flush(true)
+					return
+				}
+				heap.Push(pending, pendingLogLine{line: line, arrival: time.Now()})
+				if pending.Len() > logsReorderMaxSize && !flush(false) {
+					return
+				}
+			case <-ticker.C:
+				if !flush(false) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return merged
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// receiveLogLine returns the next merged line, or false once merged is closed.
+func receiveLogLine(t *testing.T, merged <-chan logLine) (logLine, bool) {
+	t.Helper()
+	select {
+	case line, ok := <-merged:
+		return line, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("no merged line")
+	}
+	return logLine{}, false
+}
+
+func TestMergeLogLinesOrder(t *testing.T) {
+	at := time.Unix(1541413800, 0)
+	lines := make(chan logLine)
+	merged := mergeLogLines(context.Background(), lines, time.Hour)
+	go func() {
+		// Each container is in order, b is late compared to a
+		for _, line := range []logLine{
+			{ContainerID: "a", Time: at.Add(2 * time.Second), Text: "a2"},
+			{ContainerID: "a", Time: at.Add(4 * time.Second), Text: "a4"},
+			{ContainerID: "b", Time: at.Add(1 * time.Second), Text: "b1"},
+			{ContainerID: "a", Time: at.Add(5 * time.Second), Text: "a5"},
+			{ContainerID: "b", Time: at.Add(3 * time.Second), Text: "b3"},
+		} {
+			lines <- line
+		}
+		close(lines)
+	}()
+
+	// Closing the lines flushes the pending ones without waiting for the window
+	var got []string
+	for {
+		line, ok := receiveLogLine(t, merged)
+		if !ok {
+			break
+		}
+		got = append(got, line.Text)
+	}
+	want := []string{"b1", "a2", "b3", "a4", "a5"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for index := range want {
+		if got[index] != want[index] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMergeLogLinesWindow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	window := 50 * time.Millisecond
+	lines := make(chan logLine)
+	merged := mergeLogLines(ctx, lines, window)
+
+	sent := time.Now()
+	lines <- logLine{ContainerID: "a", Time: time.Unix(1541413800, 0), Text: "a"}
+	line, ok := receiveLogLine(t, merged)
+	if !ok || line.Text != "a" {
+		t.Fatalf("got %+v, %t, want line a", line, ok)
+	}
+	if elapsed := time.Since(sent); elapsed < window {
+		t.Errorf("line sent after %s, before the %s window", elapsed, window)
+	}
+}
+
+func TestMergeLogLinesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(chan logLine)
+	merged := mergeLogLines(ctx, lines, time.Hour)
+	lines <- logLine{ContainerID: "a", Text: "pending"}
+	cancel()
+	// The pending line may not be sent once cancelled, the lines are never closed
+	for {
+		if _, ok := receiveLogLine(t, merged); !ok {
+			return
+		}
+	}
+}
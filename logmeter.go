@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	units "github.com/docker/go-units"
+)
+
+const (
+	// logsMeterWindow is the period over which the logs rates are averaged
+	logsMeterWindow     = time.Minute
+	jsonFileLogDriver   = "json-file"
+	chattiestContainers = 10
+)
+
+// LogsRate is the logging rate of a container
+type LogsRate struct {
+	ID             string
+	Name           string
+	LinesPerSecond float64
+	BytesPerSecond float64
+	Lines          uint64
+	Bytes          uint64
+	Warnings       []string
+}
+
+// logsCounter counts the lines and bytes of a container per second over
+// the meter window.
+type logsCounter struct {
+	name    string
+	lines   uint64
+	bytes   uint64
+	seconds [60]struct {
+		at    int64
+		lines uint64
+		bytes uint64
+	}
+}
+
+func (c *logsCounter) add(at time.Time, size int) {
+	second := at.Unix()
+	bucket := &c.seconds[second%int64(len(c.seconds))]
+	if bucket.at != second {
+		bucket.at, bucket.lines, bucket.bytes = second, 0, 0
+	}
+	bucket.lines++
+	bucket.bytes += uint64(size)
+	c.lines++
+	c.bytes += uint64(size)
+}
+
+// rates returns the lines and bytes per second over the window ending at now.
+func (c *logsCounter) rates(now time.Time) (lines, bytes float64) {
+	oldest := now.Add(-logsMeterWindow).Unix()
+	for _, bucket := range c.seconds {
+		if bucket.at > oldest {
+			lines += float64(bucket.lines)
+			bytes += float64(bucket.bytes)
+		}
+	}
+	return lines / logsMeterWindow.Seconds(), bytes / logsMeterWindow.Seconds()
+}
+
+// logsMeter follows the logs of every running container to measure how
+// much each of them logs.
+type logsMeter struct {
+	tailer *logTailer
+
+	mu       sync.Mutex
+	counters map[string]*logsCounter
+}
+
+func newLogsMeter(ctx context.Context, s *Server) *logsMeter {
+	m := &logsMeter{counters: make(map[string]*logsCounter)}
+	m.tailer = newLogTailer(ctx, s, func(tailedContainer) bool { return true }, m.count)
+	return m
+}
+
+// run follows the containers logs and forgets the destroyed containers.
+func (m *logsMeter) run(messages <-chan events.Message) {
+	tailed := make(chan events.Message, subscriberBufferSize)
+	defer close(tailed)
+	go m.tailer.run(tailed)
+	for msg := range messages {
+		if msg.Type == events.ContainerEventType && msg.Action == "destroy" {
+			m.mu.Lock()
+			delete(m.counters, msg.Actor.ID)
+			m.mu.Unlock()
+		}
+		tailed <- msg
+	}
+}
+
+func (m *logsMeter) count(ctx context.Context, container tailedContainer, line logLine) {
+	at := line.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counter, ok := m.counters[container.ID]
+	if !ok {
+		counter = &logsCounter{name: container.Name}
+		m.counters[container.ID] = counter
+	}
+	// The newline is part of what the container logged
+	counter.add(at, len(line.Text)+1)
+}
+
+// ranking returns the containers rates, the chattiest first.
+func (m *logsMeter) ranking() []LogsRate {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	ranking := make([]LogsRate, 0, len(m.counters))
+	for id, counter := range m.counters {
+		lines, bytes := counter.rates(now)
+		ranking = append(ranking, LogsRate{
+			ID:             id,
+			Name:           counter.name,
+			LinesPerSecond: lines,
+			BytesPerSecond: bytes,
+			Lines:          counter.lines,
+			Bytes:          counter.bytes,
+		})
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].BytesPerSecond != ranking[j].BytesPerSecond {
+			return ranking[i].BytesPerSecond > ranking[j].BytesPerSecond
+		}
+		return ranking[i].Bytes > ranking[j].Bytes
+	})
+	return ranking
+}
+
+// chattiest returns the ranking of the most logging containers, with the
+// warnings about their log files.
+func (s *Server) chattiest(ctx context.Context) []LogsRate {
+	if s.meter == nil {
+		return nil
+	}
+	ranking := s.meter.ranking()
+	if len(ranking) > chattiestContainers {
+		ranking = ranking[:chattiestContainers]
+	}
+	for index := range ranking {
+		container, err := s.docker.ContainerInspect(ctx, ranking[index].ID)
+		if err != nil {
+			continue
+		}
+		ranking[index].Warnings = logFileWarnings(container, s.logsMaxSize)
+	}
+	return ranking
+}
+
+// logFileWarnings warns about the json-file logs of a container without
+// size limit or larger than maxSize.
+func logFileWarnings(container types.ContainerJSON, maxSize int64) []string {
+	if container.HostConfig == nil || container.HostConfig.LogConfig.Type != jsonFileLogDriver {
+		return nil
+	}
+	var warnings []string
+	if _, ok := container.HostConfig.LogConfig.Config["max-size"]; !ok {
+		warnings = append(warnings, "The json-file log has no max-size option and grows without limit")
+	}
+	// The log file is only readable when the console runs on the Docker host
+	if info, err := os.Stat(container.LogPath); err == nil && maxSize > 0 && info.Size() > maxSize {
+		warnings = append(warnings, fmt.Sprintf("The json-file log is %s, above %s", units.BytesSize(float64(info.Size())), units.BytesSize(float64(maxSize))))
+	}
+	return warnings
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	logPatternAlert     = "log_pattern"
+	logAlertEventType   = "log_alert"
+	recentLogAlertsSize = 50
+)
+
+// logRule highlights the lines matching its pattern in the containers it
+// selects, and raises an alert for them when Alert is set.
+type logRule struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Alert    bool
+	Webhook  string
+	Selector string
+	selector containerSelector
+}
+
+// parseLogRule parses a rule written as a query string:
+// name=panic&pattern=panic:&container=api&label=key=value&project=shop&alert=true&webhook=URL
+// Rules without webhook use the alerts webhook.
+func parseLogRule(value, defaultWebhook string) (logRule, error) {
+	parameters, err := url.ParseQuery(value)
+	if err != nil {
+		return logRule{}, err
+	}
+	if parameters.Get("pattern") == "" {
+		return logRule{}, errors.New("log rule without pattern")
+	}
+	pattern, err := regexp.Compile(parameters.Get("pattern"))
+	if err != nil {
+		return logRule{}, err
+	}
+	rule := logRule{
+		Name:     parameters.Get("name"),
+		Pattern:  pattern,
+		Webhook:  parameters.Get("webhook"),
+		selector: newContainerSelector(parameters[forwardSelectorsParam], parameters[forwardLabelsParam], parameters[forwardProjectsParam]),
+	}
+	if rule.Name == "" {
+		rule.Name = pattern.String()
+	}
+	if alert := parameters.Get("alert"); alert != "" {
+		if rule.Alert, err = strconv.ParseBool(alert); err != nil {
+			return logRule{}, fmt.Errorf("log rule %s: %s", rule.Name, err)
+		}
+	}
+	if rule.Webhook == "" {
+		rule.Webhook = defaultWebhook
+	}
+	selection := url.Values{}
+	for _, param := range []string{forwardSelectorsParam, forwardLabelsParam, forwardProjectsParam} {
+		for _, v := range parameters[param] {
+			selection.Add(param, v)
+		}
+	}
+	rule.Selector = selection.Encode()
+	return rule, nil
+}
+
+// parseLogRules parses the rules, their names must be unique as alerts and
+// highlights refer to rules by name.
+func parseLogRules(values []string, defaultWebhook string) ([]logRule, error) {
+	var rules []logRule
+	names := make(map[string]bool)
+	for _, value := range values {
+		rule, err := parseLogRule(value, defaultWebhook)
+		if err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate log rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// match reports whether a line of a container matches the rule.
+func (r logRule) match(container tailedContainer, text string) bool {
+	return r.selector.match(container) && r.Pattern.MatchString(text)
+}
+
+// matchingLogRules returns the names of the rules matching a line.
+func matchingLogRules(rules []logRule, container tailedContainer, line logLine) []string {
+	var names []string
+	text := stripANSI(line.Text)
+	for _, rule := range rules {
+		if rule.match(container, text) {
+			names = append(names, rule.Name)
+		}
+	}
+	return names
+}
+
+// LogAlert is raised when a line matches an alerting rule. It is the
+// payload of the webhook and of the log_alert events.
+type LogAlert struct {
+	Alert       string    `json:"alert"`
+	Rule        string    `json:"rule"`
+	ContainerID string    `json:"container_id"`
+	Name        string    `json:"name"`
+	Line        string    `json:"line"`
+	Time        time.Time `json:"time"`
+	// Suppressed counts the matches since the previous alert
+	Suppressed int `json:"suppressed"`
+}
+
+// logAlerter tails the containers selected by the alerting rules. An alert
+// is raised at most once per interval for a rule and a container, the
+// matches in between are counted in the next alert.
+type logAlerter struct {
+	rules    []logRule
+	interval time.Duration
+	tailer   *logTailer
+
+	mu         sync.Mutex
+	alerted    map[logAlertKey]time.Time
+	suppressed map[logAlertKey]int
+	recent     []LogAlert
+	listeners  map[chan LogAlert]struct{}
+}
+
+// logAlertKey identifies the alerts of a rule, by index, for a container
+type logAlertKey struct {
+	rule        int
+	containerID string
+}
+
+func newLogAlerter(ctx context.Context, s *Server, rules []logRule, interval time.Duration) *logAlerter {
+	a := &logAlerter{
+		interval:   interval,
+		alerted:    make(map[logAlertKey]time.Time),
+		suppressed: make(map[logAlertKey]int),
+		listeners:  make(map[chan LogAlert]struct{}),
+	}
+	for _, rule := range rules {
+		if rule.Alert {
+			a.rules = append(a.rules, rule)
+		}
+	}
+	a.tailer = newLogTailer(ctx, s, a.selected, a.observe)
+	return a
+}
+
+func (a *logAlerter) selected(container tailedContainer) bool {
+	for _, rule := range a.rules {
+		if rule.selector.match(container) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *logAlerter) run(messages <-chan events.Message) {
+	a.tailer.run(messages)
+}
+
+func (a *logAlerter) observe(ctx context.Context, container tailedContainer, line logLine) {
+	text := stripANSI(line.Text)
+	for index, rule := range a.rules {
+		if !rule.match(container, text) {
+			continue
+		}
+		key := logAlertKey{rule: index, containerID: container.ID}
+		now := time.Now()
+		a.mu.Lock()
+		if now.Sub(a.alerted[key]) < a.interval {
+			a.suppressed[key]++
+			a.mu.Unlock()
+			continue
+		}
+		alert := LogAlert{
+			Alert:       logPatternAlert,
+			Rule:        rule.Name,
+			ContainerID: container.ID,
+			Name:        container.Name,
+			Line:        text,
+			Time:        line.Time,
+			Suppressed:  a.suppressed[key],
+		}
+		if alert.Time.IsZero() {
+			alert.Time = now
+		}
+		a.alerted[key] = now
+		delete(a.suppressed, key)
+		a.recent = append([]LogAlert{alert}, a.recent...)
+		if len(a.recent) > recentLogAlertsSize {
+			a.recent = a.recent[:recentLogAlertsSize]
+		}
+		a.publish(alert)
+		a.mu.Unlock()
+
+		log.WithFields(log.Fields{
+			"container": alert.Name,
+			"rule":      alert.Rule,
+		}).Warn(alert.Alert)
+		if rule.Webhook == "" {
+			continue
+		}
+		go func(webhook string, alert LogAlert) {
+			err := postWebhook(webhook, alert)
+			if err != nil {
+				log.Error("Alert webhook", err)
+			}
+		}(rule.Webhook, alert)
+	}
+}
+
+// publish never blocks, the lock must be held.
+func (a *logAlerter) publish(alert LogAlert) {
+	for ch := range a.listeners {
+		select {
+		case ch <- alert:
+		default:
+		}
+	}
+}
+
+func (a *logAlerter) subscribe() chan LogAlert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ch := make(chan LogAlert, subscriberBufferSize)
+	a.listeners[ch] = struct{}{}
+	return ch
+}
+
+func (a *logAlerter) unsubscribe(ch chan LogAlert) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.listeners, ch)
+}
+
+// alerts returns the latest alerts, most recent first.
+func (a *logAlerter) alerts() []LogAlert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]LogAlert(nil), a.recent...)
+}
+
+// logAlertEvent formats an alert as a server sent event.
+func logAlertEvent(alert LogAlert) (*Event, error) {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return nil, err
+	}
+	return NewEvent(logAlertEventType, string(data)), nil
+}
+
+func (s *Server) handleLogsRules() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	type response struct {
+		Rules    []logRule
+		Alerts   []LogAlert
+		Interval time.Duration
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		init.Do(func() {
+			tpl, err = s.parseTemplate("rules.html")
+		})
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := response{Rules: s.rules}
+		if s.alerter != nil {
+			data.Alerts = s.alerter.alerts()
+			data.Interval = s.alerter.interval
+		}
+		if err := tpl.ExecuteTemplate(w, "rules.html", data); err != nil {
+			log.Error(err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		err    string
+	}{
+		{name: "unique names", values: []string{"name=panic&pattern=panic:", "name=oom&pattern=out+of+memory"}},
+		{name: "duplicate names", values: []string{"name=panic&pattern=panic:", "name=panic&pattern=fatal"}, err: `duplicate log rule name "panic"`},
+		{name: "duplicate patterns without name", values: []string{"pattern=panic", "pattern=panic&alert=true"}, err: `duplicate log rule name "panic"`},
+		{name: "without pattern", values: []string{"name=panic"}, err: "log rule without pattern"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := parseLogRules(test.values, "")
+			if test.err == "" {
+				if err != nil || len(rules) != len(test.values) {
+					t.Errorf("got %d rules, %v, want %d rules", len(rules), err, len(test.values))
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestLogAlerterThrottle(t *testing.T) {
+	rules, err := parseLogRules([]string{"name=panic&pattern=panic&alert=true", "name=goroutine&pattern=goroutine&alert=true"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newLogAlerter(context.Background(), nil, rules, time.Hour)
+	web, db := tailedContainer{ID: "c1", Name: "web"}, tailedContainer{ID: "c2", Name: "db"}
+	line := logLine{Text: "panic: goroutine 1 deadlocked"}
+	a.observe(context.Background(), web, line)
+	a.observe(context.Background(), web, line)
+	a.observe(context.Background(), db, line)
+	a.observe(context.Background(), web, line)
+
+	// Each rule alerts once per container, the matches in between are
+	// counted for the next alert
+	alerts := a.alerts()
+	if len(alerts) != 4 {
+		t.Fatalf("got %d alerts, want 4: %+v", len(alerts), alerts)
+	}
+	for index, rule := range a.rules {
+		if suppressed := a.suppressed[logAlertKey{rule: index, containerID: web.ID}]; suppressed != 2 {
+			t.Errorf("rule %s suppressed %d alerts of web, want 2", rule.Name, suppressed)
+		}
+		if suppressed := a.suppressed[logAlertKey{rule: index, containerID: db.ID}]; suppressed != 0 {
+			t.Errorf("rule %s suppressed %d alerts of db, want 0", rule.Name, suppressed)
+		}
+	}
+}
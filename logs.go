@@ -3,16 +3,275 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	defaultLogsTail = "100"
+	stdoutStream    = "stdout"
+	stderrStream    = "stderr"
+)
+
+// logLine is a single demultiplexed line of a container log
+type logLine struct {
+	ContainerID string
+	Stream      string
+	Time        time.Time
+	Text        string
+	Fields      map[string]interface{}
+}
+
+// logsQuery holds the log viewer options read from the query string
+type logsQuery struct {
+	ContainersID []string
+	Tail         string
+	Since        string
+	Until        string
+	Timestamps   bool
+	Follow       bool
+	Include      string
+	Exclude      string
+	Stream       string
+	Level        string
+	Fields       string
+	Columns      string
+	ANSI         string
+
+	filter  logFilter
+	columns []string
+	// untils are the until bounds by container of an older page
+	untils map[string]string
+}
+
+func parseLogsQuery(query url.Values) (logsQuery, error) {
+	options := logsQuery{
+		ContainersID: query["containers_id"],
+		Tail:         defaultLogsTail,
+		Since:        query.Get("since"),
+		Until:        query.Get("until"),
+		Follow:       true,
+		Include:      query.Get("include"),
+		Exclude:      query.Get("exclude"),
+		Stream:       query.Get("stream"),
+		Level:        query.Get("level"),
+		Fields:       query.Get("fields"),
+		Columns:      query.Get("columns"),
+		columns:      parseLogColumns(query.Get("columns")),
+		ANSI:         query.Get("ansi"),
+	}
+	filter, err := parseLogFilter(query)
+	if err != nil {
+		return options, err
+	}
+	options.filter = filter
+	if tail, ok := query["tail"]; ok {
+		options.Tail = tail[0]
+	}
+	if options.Tail != "" && options.Tail != "all" {
+		if _, err := strconv.Atoi(options.Tail); err != nil {
+			return options, fmt.Errorf("invalid tail value %q", options.Tail)
+		}
+	}
+	for name, field := range map[string]*bool{"timestamps": &options.Timestamps, "follow": &options.Follow} {
+		// Forms send a hidden false default before the checkbox value
+		if values := query[name]; len(values) > 0 {
+			value := values[len(values)-1]
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return options, fmt.Errorf("invalid %s value %q", name, value)
+			}
+			*field = parsed
+		}
+	}
+	// Older pages bound each container, id=timestamp,id=timestamp
+	if strings.Contains(options.Until, "=") {
+		options.untils = make(map[string]string)
+		for _, bound := range strings.Split(options.Until, ",") {
+			parts := strings.SplitN(bound, "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return options, fmt.Errorf("invalid until value %q", options.Until)
+			}
+			options.untils[parts[0]] = parts[1]
+		}
+	}
+	// Nothing to follow once the until bound is reached
+	if options.Until != "" {
+		options.Follow = false
+	}
+	return options, nil
+}
+
+// paged returns the containers of an older page, the ones with an until
+// bound, or every container.
+func (q logsQuery) paged(containersID []string) []string {
+	if q.untils == nil {
+		return containersID
+	}
+	var paged []string
+	for _, containerID := range containersID {
+		if _, ok := q.untils[containerID]; ok {
+			paged = append(paged, containerID)
+		}
+	}
+	return paged
+}
+
+// containerLogsOptions always requests timestamps, they are needed to page
+// through older lines and are stripped when not asked for.
+func (q logsQuery) containerLogsOptions(containerID string) types.ContainerLogsOptions {
+	until := q.Until
+	if q.untils != nil {
+		until = q.untils[containerID]
+	}
+	return types.ContainerLogsOptions{
+		ShowStdout: q.Stream != stderrStream,
+		ShowStderr: q.Stream != stdoutStream,
+		Timestamps: true,
+		Follow:     q.Follow,
+		Tail:       q.Tail,
+		Since:      q.Since,
+		Until:      until,
+	}
+}
+
+// formatLogsTimestamp formats t the way the Docker API expects since and until.
+func formatLogsTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// scanLogLines sends each line read from r to lines.
+// Lines are expected to be prefixed by their RFC3339 timestamp.
+func scanLogLines(ctx context.Context, r io.Reader, containerID, stream string, lines chan<- logLine) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// TTY logs end their lines with \r\n
+		line := logLine{ContainerID: containerID, Stream: stream, Text: strings.TrimSuffix(scanner.Text(), "\r")}
+		if parts := strings.SplitN(line.Text, " ", 2); len(parts) == 2 {
+			if t, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
+				line.Time, line.Text = t, parts[1]
+			}
+		}
+		line.Fields, _ = parseLogFields(line.Text)
+		select {
+		case lines <- line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
+
+// streamContainerLogs demultiplexes the logs of a container into lines
+// until the logs end or ctx is done. Logs of containers with a TTY are
+// not multiplexed and only have a stdout stream.
+func (s *Server) streamContainerLogs(ctx context.Context, containerID string, options types.ContainerLogsOptions, lines chan<- logLine) error {
+	container, err := s.docker.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	logsReader, err := s.docker.ContainerLogs(ctx, containerID, options)
+	if err != nil {
+		return err
+	}
+	defer logsReader.Close()
+
+	if container.Config != nil && container.Config.Tty {
+		return scanLogLines(ctx, logsReader, containerID, stdoutStream, lines)
+	}
+
+	stdoutReader, stdoutWriter := io.Pipe()
+	stderrReader, stderrWriter := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for stream, reader := range map[string]*io.PipeReader{stdoutStream: stdoutReader, stderrStream: stderrReader} {
+		go func(stream string, reader *io.PipeReader) {
+			defer wg.Done()
+			err := scanLogLines(ctx, reader, containerID, stream, lines)
+			// Unblock the demultiplexer if scanning stopped early
+			reader.CloseWithError(err)
+		}(stream, reader)
+	}
+
+	_, err = stdcopy.StdCopy(stdoutWriter, stderrWriter, logsReader)
+	stdoutWriter.CloseWithError(err)
+	stderrWriter.CloseWithError(err)
+	wg.Wait()
+	return err
+}
+
+// streamLogs merges the lines of several containers into one channel,
+// closed once every log ended.
+func (s *Server) streamLogs(ctx context.Context, containersID []string, options func(containerID string) types.ContainerLogsOptions) <-chan logLine {
+	lines := make(chan logLine)
+	var wg sync.WaitGroup
+	for _, containerID := range containersID {
+		wg.Add(1)
+		go func(containerID string) {
+			defer wg.Done()
+			err := s.streamContainerLogs(ctx, containerID, options(containerID), lines)
+			if err != nil && ctx.Err() == nil {
+				log.Error("Docker container logs", err)
+			}
+		}(containerID)
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+	return lines
+}
+
+// resolveLogsContainers returns the containers to read the logs of,
+// every running container when none is selected.
+func (s *Server) resolveLogsContainers(ctx context.Context, containersID []string) ([]types.Container, error) {
+	if len(containersID) == 0 {
+		return s.docker.ContainerList(ctx, types.ContainerListOptions{})
+	}
+	return s.resolveContainers(containersID...)
+}
+
+type logsPrefix struct {
+	name  string
+	label string
+	color string
+}
+
+// logsPrefixes assigns each container a name prefix and a color, padded to
+// the longest name like docker-compose logs does.
+func logsPrefixes(containers []types.Container) map[string]logsPrefix {
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Names[0] < containers[j].Names[0] })
+	width := 0
+	for _, container := range containers {
+		if len(container.Names[0])-1 > width {
+			width = len(container.Names[0]) - 1
+		}
+	}
+	prefixes := make(map[string]logsPrefix, len(containers))
+	for index, container := range containers {
+		prefixes[container.ID] = logsPrefix{
+			name:  container.Names[0][1:],
+			label: fmt.Sprintf("%-*s |", width, container.Names[0][1:]),
+			color: logsComposeColors[index%len(logsComposeColors)],
+		}
+	}
+	return prefixes
+}
+
 func (s *Server) handleLogs() http.HandlerFunc {
 	var (
 		init sync.Once
@@ -28,56 +287,76 @@ func (s *Server) handleLogs() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		err = tpl.ExecuteTemplate(w, "logs.html", nil)
+		query, err := parseLogsQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if containerID, ok := mux.Vars(r)["id"]; ok {
+			query.ContainersID = []string{containerID}
+		}
+		response := struct {
+			logsQuery
+			Chattiest []LogsRate
+		}{query, s.chattiest(r.Context())}
+		err = tpl.ExecuteTemplate(w, "logs.html", response)
 		if err != nil {
 			log.Error(err)
 		}
 	}
 }
 
+// olderPageUntil returns the until bounds of the next older page from the
+// time of the oldest line read of each container, as id=timestamp pairs.
+// Each container is tailed on its own, and goes on before its own oldest
+// line. Docker's until is inclusive, the bounds are moved back a nanosecond
+// not to repeat the lines.
+func olderPageUntil(oldest map[string]time.Time) string {
+	bounds := make([]string, 0, len(oldest))
+	for containerID, t := range oldest {
+		bounds = append(bounds, containerID+"="+formatLogsTimestamp(t.Add(-time.Nanosecond)))
+	}
+	sort.Strings(bounds)
+	return strings.Join(bounds, ",")
+}
+
+// handleLogsEvents streams the logs as server sent events. Reads without
+// follow end with an "end" event holding the until bound of the next older
+// page.
 func (s *Server) handleLogsEvents() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		log.Print("New Event listener")
-		containersID := r.URL.Query()["containers_id"]
-
-		if len(containersID) == 0 {
-			containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{})
-			if err != nil && err != context.Canceled {
-				log.Error("Docker containers list", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			if len(containers) == 0 {
-				log.Error("No running container")
-				http.Error(w, "No running container", http.StatusNotFound)
-				return
-			}
-
-			for _, container := range containers {
-				containersID = append(containersID, container.ID)
-			}
+		query, err := parseLogsQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ansi, err := parseANSIMode(query.ANSI, ansiHTML, ansiHTML, ansiStrip)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		logsReaders := []io.Reader{}
-		for _, containerID := range containersID {
-			logsReader, err := s.docker.ContainerLogs(
-				context.Background(),
-				containerID,
-				types.ContainerLogsOptions{
-					Follow:     true,
-					ShowStdout: true,
-					ShowStderr: true,
-				},
-			)
-			if err != nil {
-				log.Error("Docker container logs", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			defer logsReader.Close()
-			logsReaders = append(logsReaders, logsReader)
+		containers, err := s.resolveLogsContainers(ctx, query.ContainersID)
+		if err != nil && err != context.Canceled {
+			log.Error("Docker containers list", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(containers) == 0 {
+			log.Error("No running container")
+			http.Error(w, "No running container", http.StatusNotFound)
+			return
+		}
+		containersID := make([]string, len(containers))
+		for index, container := range containers {
+			containersID[index] = container.ID
+		}
+		prefixes := logsPrefixes(containers)
+		tailed := make(map[string]tailedContainer, len(containers))
+		for _, container := range containers {
+			tailed[container.ID] = tailedContainer{ID: container.ID, Name: container.Names[0][1:], Labels: container.Labels}
 		}
 
 		lastEventID := r.Header.Get("Last-Event-ID")
@@ -93,18 +372,6 @@ func (s *Server) handleLogsEvents() http.HandlerFunc {
 			return
 		}
 
-		notify := w.(http.CloseNotifier).CloseNotify()
-		go func() {
-			<-notify
-			log.Println("HTTP connection just closed.")
-			// for _, logsReader := range logsReaders {
-			// 	err := logsReader.Close()
-			// 	if err != nil {
-			// 		log.Error(err)
-			// 	}
-			// }
-		}()
-
 		w.Header().Set("Connection", "keep-alive")
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Transfer-Encoding", "chunked")
@@ -112,15 +379,39 @@ func (s *Server) handleLogsEvents() http.HandlerFunc {
 		w.Header().Set("Pragma", "no-cache")
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 
-		multiReader := io.MultiReader(logsReaders...)
-		scanner := bufio.NewScanner(multiReader)
-		for scanner.Scan() {
-			event := NewEvent("", scanner.Text())
-			fmt.Fprint(w, event)
+		oldest := make(map[string]time.Time, len(containersID))
+		lines := s.streamLogs(ctx, query.paged(containersID), query.containerLogsOptions)
+		if len(containersID) > 1 {
+			lines = mergeLogLines(ctx, lines, logsReorderWindow)
+		}
+		for line := range lines {
+			if first, ok := oldest[line.ContainerID]; !line.Time.IsZero() && (!ok || line.Time.Before(first)) {
+				oldest[line.ContainerID] = line.Time
+			}
+			if !query.filter.match(line) {
+				continue
+			}
+			entry := newLogEntry(line, query.columns, query.Timestamps)
+			if ansi == ansiHTML {
+				entry.HTML = ansiToHTML(line.Text)
+			} else {
+				entry.Text = stripANSI(line.Text)
+			}
+			entry.Name, entry.Color = prefixes[line.ContainerID].label, prefixes[line.ContainerID].color
+			entry.Rules = matchingLogRules(s.rules, tailed[line.ContainerID], line)
+			data, err := json.Marshal(entry)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			fmt.Fprint(w, NewEvent("", string(data)))
 			f.Flush()
 		}
-		if err := scanner.Err(); err != nil {
-			log.Fatal("reading standard input:", err)
+		if ctx.Err() != nil {
+			log.Println("HTTP connection just closed.")
+			return
 		}
+		fmt.Fprint(w, NewEvent("end", olderPageUntil(oldest)))
+		f.Flush()
 	}
 }
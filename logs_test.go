@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOlderPageUntil(t *testing.T) {
+	at := time.Unix(1500000000, 500)
+	tests := []struct {
+		name   string
+		oldest map[string]time.Time
+		want   string
+	}{
+		{name: "no lines", oldest: map[string]time.Time{}, want: ""},
+		{name: "one container", oldest: map[string]time.Time{"a": at}, want: "a=1500000000.000000499"},
+		{
+			name:   "each container before its oldest line",
+			oldest: map[string]time.Time{"b": at, "a": at.Add(-time.Hour)},
+			want:   "a=1499996400.000000499,b=1500000000.000000499",
+		},
+		{name: "second boundary", oldest: map[string]time.Time{"a": time.Unix(1500000000, 0)}, want: "a=1499999999.999999999"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := olderPageUntil(test.oldest); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOlderPageOptions(t *testing.T) {
+	oldest := map[string]time.Time{"a": time.Unix(1500000000, 0), "b": time.Unix(1500003600, 0)}
+	query, err := parseLogsQuery(url.Values{"follow": {"true"}, "until": {olderPageUntil(oldest)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Follow {
+		t.Error("older page is followed")
+	}
+	// c had no line left on the previous page
+	if got := query.paged([]string{"a", "b", "c"}); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("paged got %v, want [a b]", got)
+	}
+	for containerID, want := range map[string]string{"a": "1499999999.999999999", "b": "1500003599.999999999"} {
+		if got := query.containerLogsOptions(containerID).Until; got != want {
+			t.Errorf("%s until got %q, want %q", containerID, got, want)
+		}
+	}
+
+	query, err = parseLogsQuery(url.Values{"until": {"2018-11-05T10:30:00Z"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := query.paged([]string{"a", "b"}); len(got) != 2 {
+		t.Errorf("paged got %v, want every container", got)
+	}
+	if got := query.containerLogsOptions("a").Until; got != "2018-11-05T10:30:00Z" {
+		t.Errorf("until got %q", got)
+	}
+
+	if _, err := parseLogsQuery(url.Values{"until": {"a=,b=1"}}); err == nil {
+		t.Error("invalid until bounds accepted")
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	logsSearchPageSize = 50
+	logsContextSpan    = 30 * time.Second
+)
+
+// logsSearchRanges are the time range facets of the logs search
+var logsSearchRanges = []struct {
+	Name  string
+	Value string
+	Span  time.Duration
+}{
+	{"Last 15 minutes", "15m", 15 * time.Minute},
+	{"Last hour", "1h", time.Hour},
+	{"Last 24 hours", "24h", 24 * time.Hour},
+	{"Last 7 days", "168h", 7 * 24 * time.Hour},
+}
+
+func (s *Server) handleLogsSearch() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	type hit struct {
+		Time        time.Time
+		Container   string
+		ContainerID string
+		Stream      string
+		Level       string
+		Message     string
+		ContextURL  string
+	}
+	type facet struct {
+		Name     string
+		Count    int
+		URL      string
+		Selected bool
+	}
+	type searchResponse struct {
+		Enabled    bool
+		Query      string
+		Total      uint64
+		Hits       []hit
+		Ranges     []facet
+		Containers []facet
+		Levels     []facet
+		NextURL    string
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		init.Do(func() {
+			tpl, err = s.parseTemplate("logsearch.html")
+		})
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		parameters := r.URL.Query()
+		response := searchResponse{Enabled: s.collector != nil, Query: parameters.Get("q")}
+		if s.collector == nil {
+			if err := tpl.ExecuteTemplate(w, "logsearch.html", response); err != nil {
+				log.Error(err)
+			}
+			return
+		}
+
+		page, _ := strconv.Atoi(parameters.Get("page"))
+		if page < 0 {
+			page = 0
+		}
+		now := time.Now()
+		conjuncts := []query.Query{bleve.NewMatchAllQuery()}
+		if response.Query != "" {
+			conjuncts = append(conjuncts, bleve.NewQueryStringQuery(response.Query))
+		}
+		if value := parameters.Get("range"); value != "" {
+			span, err := time.ParseDuration(value)
+			if err != nil {
+				http.Error(w, "Invalid range", http.StatusBadRequest)
+				return
+			}
+			since := bleve.NewDateRangeQuery(now.Add(-span), time.Time{})
+			since.SetField("timestamp")
+			conjuncts = append(conjuncts, since)
+		}
+		for _, field := range []string{"container", "level"} {
+			if value := parameters.Get(field); value != "" {
+				term := bleve.NewTermQuery(value)
+				term.SetField(field)
+				conjuncts = append(conjuncts, term)
+			}
+		}
+
+		request := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(conjuncts...), logsSearchPageSize, page*logsSearchPageSize, false)
+		request.SortBy([]string{"-timestamp"})
+		request.Fields = []string{"container", "container_id", "stream", "level", "message"}
+		timestampFacet := bleve.NewFacetRequest("timestamp", len(logsSearchRanges))
+		for _, timeRange := range logsSearchRanges {
+			timestampFacet.AddDateTimeRange(timeRange.Value, now.Add(-timeRange.Span), time.Time{})
+		}
+		request.AddFacet("timestamp", timestampFacet)
+		request.AddFacet("container", bleve.NewFacetRequest("container", 20))
+		request.AddFacet("level", bleve.NewFacetRequest("level", 10))
+
+		result, err := s.collector.index.Search(request)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// facetURL returns the current search URL with one parameter changed
+		facetURL := func(name, value string) string {
+			values := url.Values{}
+			for key, list := range parameters {
+				values[key] = list
+			}
+			values.Del("page")
+			if parameters.Get(name) == value {
+				values.Del(name)
+			} else {
+				values.Set(name, value)
+			}
+			return "/logs/search?" + values.Encode()
+		}
+
+		response.Total = result.Total
+		for _, match := range result.Hits {
+			h := hit{
+				Time:        logDocumentTime(match.ID),
+				ContainerID: formatLogField(match.Fields["container_id"]),
+				Container:   formatLogField(match.Fields["container"]),
+				Stream:      formatLogField(match.Fields["stream"]),
+				Level:       formatLogField(match.Fields["level"]),
+				Message:     formatLogField(match.Fields["message"]),
+			}
+			h.ContextURL = "/containers/" + h.ContainerID + "/logs?" + url.Values{
+				"since":      {formatLogsTimestamp(h.Time.Add(-logsContextSpan))},
+				"until":      {formatLogsTimestamp(h.Time.Add(logsContextSpan))},
+				"tail":       {"all"},
+				"timestamps": {"true"},
+			}.Encode()
+			response.Hits = append(response.Hits, h)
+		}
+		if facetResult, ok := result.Facets["timestamp"]; ok {
+			counts := map[string]int{}
+			for _, dateRange := range facetResult.DateRanges {
+				counts[dateRange.Name] = dateRange.Count
+			}
+			for _, timeRange := range logsSearchRanges {
+				response.Ranges = append(response.Ranges, facet{
+					Name:     timeRange.Name,
+					Count:    counts[timeRange.Value],
+					URL:      facetURL("range", timeRange.Value),
+					Selected: parameters.Get("range") == timeRange.Value,
+				})
+			}
+		}
+		for field, facets := range map[string]*[]facet{"container": &response.Containers, "level": &response.Levels} {
+			facetResult, ok := result.Facets[field]
+			if !ok {
+				continue
+			}
+			for _, term := range facetResult.Terms {
+				*facets = append(*facets, facet{
+					Name:     term.Term,
+					Count:    term.Count,
+					URL:      facetURL(field, term.Term),
+					Selected: parameters.Get(field) == term.Term,
+				})
+			}
+		}
+		if uint64((page+1)*logsSearchPageSize) < result.Total {
+			values := url.Values{}
+			for key, list := range parameters {
+				values[key] = list
+			}
+			values.Set("page", strconv.Itoa(page+1))
+			response.NextURL = "/logs/search?" + values.Encode()
+		}
+
+		err = tpl.ExecuteTemplate(w, "logsearch.html", response)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// handleLogsCollect starts or stops collecting the logs of a container.
+func (s *Server) handleLogsCollect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.collector == nil {
+			http.Error(w, "Logs collector disabled", http.StatusNotFound)
+			return
+		}
+		containerID := mux.Vars(r)["id"]
+		container, err := s.docker.ContainerInspect(r.Context(), containerID)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		collect, _ := strconv.ParseBool(r.FormValue("collect"))
+		s.collector.setSelected(container.ID, container.Name[1:], collect)
+		http.Redirect(w, r, "/containers/"+container.ID, http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	sinkDialTimeout = 5 * time.Second
+	// gelfMaxDatagramSize is the largest unchunked GELF UDP message
+	gelfMaxDatagramSize = 8192
+	// syslogUserFacility is the syslog facility of forwarded lines
+	syslogUserFacility = 1
+)
+
+// forwardedLine is a log line sent to a sink
+type forwardedLine struct {
+	Time        time.Time `json:"timestamp"`
+	Container   string    `json:"container"`
+	ContainerID string    `json:"container_id"`
+	Stream      string    `json:"stream"`
+	Level       string    `json:"level,omitempty"`
+	Message     string    `json:"message"`
+}
+
+// syslogSeverity maps a line to a syslog severity, stderr lines with no
+// detectable level are errors.
+func (l forwardedLine) syslogSeverity() int {
+	switch l.Level {
+	case levelFatal.String():
+		return 2
+	case levelError.String():
+		return 3
+	case levelWarning.String():
+		return 4
+	case levelInfo.String():
+		return 6
+	case levelDebug.String(), levelTrace.String():
+		return 7
+	}
+	if l.Stream == stderrStream {
+		return 3
+	}
+	return 6
+}
+
+// logSink delivers batches of lines to a remote log server
+type logSink interface {
+	send(lines []forwardedLine) error
+	close() error
+}
+
+// newLogSink returns the sink of an address such as syslog+udp://host:514,
+// syslog+tcp://host:601, gelf+udp://host:12201, gelf+tcp://host:12201 or
+// an http(s) URL receiving NDJSON.
+func newLogSink(address *url.URL) (logSink, error) {
+	switch address.Scheme {
+	case "syslog+udp", "syslog+tcp":
+		return &streamSink{network: address.Scheme[len("syslog+"):], address: address.Host, encode: encodeSyslog}, nil
+	case "gelf+udp":
+		return &streamSink{network: "udp", address: address.Host, encode: encodeGELFDatagram}, nil
+	case "gelf+tcp":
+		return &streamSink{network: "tcp", address: address.Host, encode: encodeGELFFrame}, nil
+	case "http", "https":
+		target := *address
+		target.RawQuery = ""
+		return &httpSink{url: target.String()}, nil
+	}
+	return nil, fmt.Errorf("unknown log sink scheme %q", address.Scheme)
+}
+
+var hostname, _ = os.Hostname()
+
+// encodeSyslog formats a line as a RFC 5424 message. Lines are octet
+// counted, which TCP servers require and UDP servers tolerate.
+func encodeSyslog(line forwardedLine, network string) ([]byte, error) {
+	message := fmt.Sprintf("<%d>1 %s %s %s - - - %s",
+		syslogUserFacility*8+line.syslogSeverity(),
+		line.Time.UTC().Format(time.RFC3339Nano),
+		hostname,
+		line.Container,
+		line.Message,
+	)
+	if network == "tcp" {
+		return []byte(fmt.Sprintf("%d %s", len(message), message)), nil
+	}
+	return []byte(message), nil
+}
+
+func gelfMessage(line forwardedLine) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"version":         "1.1",
+		"host":            hostname,
+		"short_message":   line.Message,
+		"timestamp":       float64(line.Time.UnixNano()) / 1e9,
+		"level":           line.syslogSeverity(),
+		"_container_name": line.Container,
+		"_container_id":   line.ContainerID,
+		"_stream":         line.Stream,
+	})
+}
+
+// encodeGELFDatagram compresses the messages too large for a datagram.
+func encodeGELFDatagram(line forwardedLine, network string) ([]byte, error) {
+	message, err := gelfMessage(line)
+	if err != nil || len(message) <= gelfMaxDatagramSize {
+		return message, err
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(message)
+	writer.Close()
+	if compressed.Len() > gelfMaxDatagramSize {
+		return nil, fmt.Errorf("GELF message of %d bytes too large", compressed.Len())
+	}
+	return compressed.Bytes(), nil
+}
+
+// encodeGELFFrame terminates messages with a null byte, as GELF TCP expects.
+func encodeGELFFrame(line forwardedLine, network string) ([]byte, error) {
+	message, err := gelfMessage(line)
+	return append(message, 0), err
+}
+
+// streamSink writes each line to a UDP or TCP connection, dialed lazily and
+// again after a failure.
+type streamSink struct {
+	network string
+	address string
+	encode  func(line forwardedLine, network string) ([]byte, error)
+	conn    net.Conn
+}
+
+func (s *streamSink) send(lines []forwardedLine) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, sinkDialTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	for _, line := range lines {
+		message, err := s.encode(line, s.network)
+		if err != nil {
+			// Lines that can't be encoded are skipped, retrying won't help
+			continue
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(sinkDialTimeout))
+		if _, err := s.conn.Write(message); err != nil {
+			s.close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *streamSink) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// httpSink posts batches of lines as NDJSON
+type httpSink struct {
+	url string
+}
+
+func (s *httpSink) send(lines []forwardedLine) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, line := range lines {
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+	response, err := webhookClient.Post(s.url, "application/x-ndjson", &body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s answered %s", s.url, response.Status)
+	}
+	return nil
+}
+
+func (s *httpSink) close() error {
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	log "github.com/sirupsen/logrus"
+)
+
+// tailedContainer identifies a container whose logs are followed
+type tailedContainer struct {
+	ID     string
+	Name   string
+	Labels map[string]string
+}
+
+// logTailer follows the logs of the running containers it accepts,
+// including the ones starting later, and hands every line to handle.
+type logTailer struct {
+	ctx    context.Context
+	server *Server
+	accept func(container tailedContainer) bool
+	// since returns the since option of a container logs, resumeSince
+	// unless replaced
+	since  func(container tailedContainer) string
+	handle func(ctx context.Context, container tailedContainer, line logLine)
+	// stream streams the logs of a container, the server's by default
+	stream func(ctx context.Context, containerID string, options types.ContainerLogsOptions, lines chan<- logLine) error
+	// started is when the tailer was created
+	started time.Time
+
+	mu      sync.Mutex
+	tailing map[string]containerTail
+	// tails counts the tails started, to tell them apart
+	tails uint64
+	// handled is the time of the last line handled of each container
+	handled map[string]time.Time
+}
+
+// containerTail is the tail following a container logs
+type containerTail struct {
+	cancel context.CancelFunc
+	id     uint64
+}
+
+func newLogTailer(ctx context.Context, s *Server, accept func(tailedContainer) bool, handle func(context.Context, tailedContainer, logLine)) *logTailer {
+	t := &logTailer{
+		ctx:     ctx,
+		server:  s,
+		accept:  accept,
+		handle:  handle,
+		started: time.Now(),
+		tailing: make(map[string]containerTail),
+		handled: make(map[string]time.Time),
+	}
+	t.since = t.resumeSince
+	if s != nil {
+		t.stream = s.streamContainerLogs
+	}
+	return t
+}
+
+// resumeSince only handles what is logged from the tailer creation on, and
+// only once: the logs of a container tailed again, once restarted, resume
+// after the last line handled.
+func (t *logTailer) resumeSince(container tailedContainer) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if last, ok := t.handled[container.ID]; ok {
+		return formatLogsTimestamp(last.Add(time.Nanosecond))
+	}
+	return formatLogsTimestamp(t.started)
+}
+
+// run tails the accepted running containers then the ones started later,
+// until messages is closed.
+func (t *logTailer) run(messages <-chan events.Message) {
+	containers, err := t.server.docker.ContainerList(t.ctx, types.ContainerListOptions{})
+	if err != nil {
+		log.Error("Logs tailer containers list", err)
+	}
+	for _, c := range containers {
+		container := tailedContainer{ID: c.ID, Name: c.Names[0][1:], Labels: c.Labels}
+		if t.accept(container) {
+			t.tail(container)
+		}
+	}
+	for msg := range messages {
+		if msg.Type == events.ContainerEventType && msg.Action == "destroy" {
+			t.mu.Lock()
+			delete(t.handled, msg.Actor.ID)
+			t.mu.Unlock()
+		}
+		if msg.Type != events.ContainerEventType || msg.Action != "start" {
+			continue
+		}
+		// Container events attributes hold the name, the image and the labels
+		container := tailedContainer{ID: msg.Actor.ID, Name: msg.Actor.Attributes["name"], Labels: msg.Actor.Attributes}
+		if t.accept(container) {
+			t.tail(container)
+		}
+	}
+}
+
+// tail follows the logs of a container. A tail still running, such as the
+// one of a container restarting, is stopped and replaced.
+func (t *logTailer) tail(container tailedContainer) {
+	t.mu.Lock()
+	if previous, ok := t.tailing[container.ID]; ok {
+		previous.cancel()
+	}
+	t.tails++
+	ctx, cancel := context.WithCancel(t.ctx)
+	current := containerTail{cancel: cancel, id: t.tails}
+	t.tailing[container.ID] = current
+	t.mu.Unlock()
+
+	go func() {
+		defer func() {
+			t.mu.Lock()
+			if t.tailing[container.ID].id == current.id {
+				delete(t.tailing, container.ID)
+			}
+			t.mu.Unlock()
+			cancel()
+		}()
+		options := types.ContainerLogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Timestamps: true,
+			Follow:     true,
+			Since:      t.since(container),
+		}
+		lines := make(chan logLine)
+		go func() {
+			defer close(lines)
+			err := t.stream(ctx, container.ID, options, lines)
+			if err != nil && ctx.Err() == nil {
+				log.Error("Logs tailer", err)
+			}
+		}()
+		for line := range lines {
+			// Lines still read once stopped or replaced are dropped
+			if ctx.Err() != nil {
+				continue
+			}
+			if !line.Time.IsZero() {
+				t.mu.Lock()
+				t.handled[container.ID] = line.Time
+				t.mu.Unlock()
+			}
+			t.handle(ctx, container, line)
+		}
+	}()
+}
+
+// stop stops following the logs of a container.
+func (t *logTailer) stop(containerID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if current, ok := t.tailing[containerID]; ok {
+		current.cancel()
+	}
+}
+
+// tailed returns the number of containers whose logs are followed.
+func (t *logTailer) tailed() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.tailing)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestLogTailerResumeSince(t *testing.T) {
+	tailer := newLogTailer(context.Background(), nil, nil, nil)
+	container := tailedContainer{ID: "c1"}
+	if got, want := tailer.resumeSince(container), formatLogsTimestamp(tailer.started); got != want {
+		t.Errorf("not tailed yet got %q, want the tailer creation %q", got, want)
+	}
+	tailer.handled[container.ID] = time.Unix(1541413800, 999999999)
+	if got, want := tailer.resumeSince(container), "1541413801.000000000"; got != want {
+		t.Errorf("tailed again got %q, want %q", got, want)
+	}
+}
+
+func TestLogTailerReplacesTail(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handled := make(chan string)
+	tailer := newLogTailer(ctx, nil, nil, func(ctx context.Context, container tailedContainer, line logLine) {
+		handled <- line.Text
+	})
+	streams := make(chan chan<- logLine)
+	stopped := make(chan string, 2)
+	tailer.stream = func(ctx context.Context, containerID string, options types.ContainerLogsOptions, lines chan<- logLine) error {
+		streams <- lines
+		<-ctx.Done()
+		stopped <- containerID
+		return ctx.Err()
+	}
+	container := tailedContainer{ID: "c1"}
+
+	tailer.tail(container)
+	<-streams
+	// The container restarts before its first tail ended
+	tailer.tail(container)
+	second := <-streams
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first tail not stopped")
+	}
+	// The first tail exiting doesn't forget the second one
+	time.Sleep(50 * time.Millisecond)
+	if tailed := tailer.tailed(); tailed != 1 {
+		t.Fatalf("got %d tails, want 1", tailed)
+	}
+	go func() { second <- logLine{Text: "restarted"} }()
+	select {
+	case text := <-handled:
+		if text != "restarted" {
+			t.Errorf("handled %q, want the line of the second tail", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second tail line not handled")
+	}
+
+	tailer.stop(container.ID)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second tail not stopped")
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	units "github.com/docker/go-units"
 	"github.com/gorilla/handlers"
 	log "github.com/sirupsen/logrus"
 	"github.com/skratchdot/open-golang/open"
@@ -13,6 +16,18 @@ import (
 
 const applicationName = "docker-console"
 
+// stringsFlag is a flag which can be repeated
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 // Version is the application version
 // Set at build time
 var Version string
@@ -21,13 +36,56 @@ func main() {
 	showVersion := flag.Bool("version", false, fmt.Sprintf("Show %s version.", applicationName))
 	openNewTab := flag.Bool("open", true, "Opens or not a new browser tab when launching.")
 	port := flag.String("port", "4242", fmt.Sprintf("%s HTTP port.", applicationName))
+	crashLoopWindow := flag.Duration("crash-loop-window", 5*time.Minute, "Period over which container restarts are counted.")
+	crashLoopThreshold := flag.Int("crash-loop-threshold", 5, "Restarts within the crash loop window flagging a crash loop.")
+	alertWebhook := flag.String("alert-webhook", "", "URL receiving crash loop and OOM kill alerts as JSON.")
+	logsCollector := flag.Bool("logs-collector", false, "Enables indexing the logs of containers selected from their page.")
+	collectLogs := flag.String("collect-logs", "", "Comma separated names or IDs of the containers whose logs are indexed for search, * for all.")
+	logsRetention := flag.Duration("logs-retention", 7*24*time.Hour, "Age after which collected log lines are deleted.")
+	logsRetentionLines := flag.Uint64("logs-retention-lines", 1000000, "Maximum number of collected log lines kept.")
+	logsRetentionSize := flag.String("logs-retention-size", "500MB", "Maximum size of the collected log messages kept, 0 for no limit.")
+	var forwardLogs stringsFlag
+	flag.Var(&forwardLogs, "forward-logs", "Forwards logs to syslog+udp://, syslog+tcp://, gelf+udp://, gelf+tcp:// or http(s):// addresses. "+
+		"Containers are selected with the container, label and project query parameters. Can be repeated.")
+	var logRules stringsFlag
+	flag.Var(&logRules, "log-rule", "Highlights log lines matching a rule such as name=panic&pattern=panic:&container=api&alert=true. "+
+		"Alerting rules raise events and call their webhook, or the alert webhook. Can be repeated.")
+	logAlertInterval := flag.Duration("log-alert-interval", time.Minute, "Minimum period between two alerts of a log rule for a container.")
+	logsMeter := flag.Bool("logs-meter", false, "Measures how much each running container logs.")
+	logsMaxSize := flag.String("logs-max-size", "100MB", "Size above which a container json-file log is warned about.")
 	flag.Parse()
 	if *showVersion {
 		fmt.Println(Version)
 		return
 	}
 
-	server, err := NewServer()
+	maxLogSize, err := units.FromHumanSize(*logsMaxSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	retentionSize, err := units.FromHumanSize(*logsRetentionSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	config := Config{
+		CrashLoopWindow:    *crashLoopWindow,
+		CrashLoopThreshold: *crashLoopThreshold,
+		AlertWebhook:       *alertWebhook,
+		LogsCollector:      *logsCollector,
+		LogsRetention:      *logsRetention,
+		LogsRetentionLines: *logsRetentionLines,
+		LogsRetentionSize:  retentionSize,
+		ForwardLogs:        forwardLogs,
+		LogRules:           logRules,
+		LogAlertInterval:   *logAlertInterval,
+		LogsMeter:          *logsMeter,
+		LogsMaxSize:        maxLogSize,
+	}
+	if *collectLogs != "" {
+		config.CollectLogs = strings.Split(*collectLogs, ",")
+	}
+	server, err := NewServer(config)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+func (s *Server) handleNetwork() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	type subnet struct {
+		Subnet  string
+		Gateway string
+	}
+	type container struct {
+		ID          string
+		Name        string
+		IPv4Address string
+		IPv6Address string
+	}
+	type networkResponse struct {
+		ID         string
+		Name       string
+		Driver     string
+		Scope      string
+		Created    string
+		Internal   bool
+		Attachable bool
+		Subnets    []subnet
+		Labels     map[string]string
+		Containers []container
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		init.Do(func() {
+			tpl, err = s.parseTemplate("network.html")
+		})
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		network, err := s.docker.NetworkInspect(r.Context(), mux.Vars(r)["id"], types.NetworkInspectOptions{})
+		if client.IsErrNotFound(err) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := networkResponse{
+			ID:         network.ID,
+			Name:       network.Name,
+			Driver:     network.Driver,
+			Scope:      network.Scope,
+			Created:    network.Created.Format("2006-01-02 15:04:05"),
+			Internal:   network.Internal,
+			Attachable: network.Attachable,
+			Labels:     network.Labels,
+		}
+		for _, config := range network.IPAM.Config {
+			response.Subnets = append(response.Subnets, subnet{Subnet: config.Subnet, Gateway: config.Gateway})
+		}
+		for id, endpoint := range network.Containers {
+			response.Containers = append(response.Containers, container{
+				ID:          id,
+				Name:        endpoint.Name,
+				IPv4Address: endpoint.IPv4Address,
+				IPv6Address: endpoint.IPv6Address,
+			})
+		}
+		sort.Slice(response.Containers, func(i, j int) bool {
+			return response.Containers[i].Name < response.Containers[j].Name
+		})
+		if err := tpl.ExecuteTemplate(w, "network.html", response); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
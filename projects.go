@@ -0,0 +1,100 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// The labels docker-compose sets on the objects of a project
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+)
+
+// handleProject shows the containers, networks and volumes of a compose
+// project, found by their compose project label.
+func (s *Server) handleProject() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	type container struct {
+		ID      string
+		Name    string
+		Service string
+		State   string
+		Status  string
+	}
+	type network struct {
+		ID   string
+		Name string
+	}
+	type projectResponse struct {
+		Name       string
+		Containers []container
+		Networks   []network
+		Volumes    []string
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		init.Do(func() {
+			tpl, err = s.parseTemplate("project.html")
+		})
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		name := mux.Vars(r)["name"]
+		byProject := filters.NewArgs(filters.Arg("label", composeProjectLabel+"="+name))
+		containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: byProject})
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(containers) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		response := projectResponse{Name: name}
+		for _, c := range containers {
+			response.Containers = append(response.Containers, container{
+				ID:      c.ID,
+				Name:    c.Names[0][1:],
+				Service: c.Labels[composeServiceLabel],
+				State:   c.State,
+				Status:  c.Status,
+			})
+		}
+		sort.Slice(response.Containers, func(i, j int) bool {
+			return response.Containers[i].Service < response.Containers[j].Service
+		})
+		networks, err := s.docker.NetworkList(ctx, types.NetworkListOptions{Filters: byProject})
+		if err != nil {
+			logrus.Error(err)
+		}
+		for _, n := range networks {
+			response.Networks = append(response.Networks, network{ID: n.ID, Name: n.Name})
+		}
+		volumes, err := s.docker.VolumeList(ctx, byProject)
+		if err != nil {
+			logrus.Error(err)
+		}
+		for _, vol := range volumes.Volumes {
+			response.Volumes = append(response.Volumes, vol.Name)
+		}
+		if err := tpl.ExecuteTemplate(w, "project.html", response); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
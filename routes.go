@@ -12,17 +12,47 @@ func (s *Server) routes() {
 
 	s.router.HandleFunc("/images", s.handleImages()).Methods(http.MethodGet)
 	s.router.HandleFunc("/images", s.handleImagesClean()).Methods(http.MethodDelete)
+	s.router.HandleFunc("/images/events", s.handleImagesEvents())
 	s.router.HandleFunc("/images/{id}", s.handleImage()).Methods(http.MethodGet)
+	s.router.HandleFunc("/images/{id}/run", s.handleImageRun()).Methods(http.MethodGet)
 
 	s.router.HandleFunc("/containers", s.handleContainers()).Methods(http.MethodGet)
+	s.router.HandleFunc("/containers", s.handleContainerCreate()).Methods(http.MethodPost)
+	s.router.HandleFunc("/containers/events", s.handleContainersEvents())
 	s.router.HandleFunc("/containers/{id}", s.handleContainer()).Methods(http.MethodGet)
+	s.router.HandleFunc("/containers/{id}/logs", s.handleLogs()).Methods(http.MethodGet)
+	s.router.HandleFunc("/containers/{id}/collect", s.handleLogsCollect()).Methods(http.MethodPost)
+	s.router.HandleFunc("/containers/{id}/resources", s.handleContainerUpdate()).Methods(http.MethodPost)
+
+	s.router.HandleFunc("/health", s.handleHealth()).Methods(http.MethodGet)
 
 	s.router.HandleFunc("/volumes", s.handleVolumes()).Methods(http.MethodGet)
+	s.router.HandleFunc("/volumes/{name}", s.handleVolume()).Methods(http.MethodGet)
+
+	s.router.HandleFunc("/disk", s.handleDisk()).Methods(http.MethodGet)
+
+	s.router.HandleFunc("/networks/{id}", s.handleNetwork()).Methods(http.MethodGet)
+
+	s.router.HandleFunc("/projects/{name}", s.handleProject()).Methods(http.MethodGet)
 
 	s.router.HandleFunc("/logs", s.handleLogs()).Methods(http.MethodGet)
 	s.router.HandleFunc("/logs/events", s.handleLogsEvents())
+	s.router.HandleFunc("/logs/download", s.handleLogsDownload()).Methods(http.MethodGet)
+	s.router.HandleFunc("/logs/search", s.handleLogsSearch()).Methods(http.MethodGet)
+	s.router.HandleFunc("/logs/rules", s.handleLogsRules()).Methods(http.MethodGet)
+	s.router.HandleFunc("/logs/forwarding", s.handleLogsForwarding()).Methods(http.MethodGet)
 
 	s.router.HandleFunc("/search", s.handleSearch()).Methods(http.MethodGet)
+	s.router.HandleFunc("/search/suggest", s.handleSearchSuggest()).Methods(http.MethodGet)
+	s.router.HandleFunc("/search/status", s.handleSearchStatus()).Methods(http.MethodGet)
+
+	s.router.HandleFunc("/views", s.handleViews()).Methods(http.MethodGet)
+	s.router.HandleFunc("/views", s.handleViewSave()).Methods(http.MethodPost)
+	s.router.HandleFunc("/views/{name}/delete", s.handleViewDelete()).Methods(http.MethodPost)
+
+	s.router.HandleFunc("/bulk", s.handleBulk()).Methods(http.MethodPost)
+	s.router.HandleFunc("/bulk/{id}", s.handleBulkJob()).Methods(http.MethodGet)
+	s.router.HandleFunc("/bulk/{id}/events", s.handleBulkEvents())
 
 	s.router.HandleFunc("/events", s.handleEvents())
 
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	units "github.com/docker/go-units"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+var restartPolicies = []string{"no", "always", "unless-stopped", "on-failure"}
+
+// splitLines returns the non empty trimmed lines of a textarea value.
+func splitLines(value string) (lines []string) {
+	scanner := bufio.NewScanner(strings.NewReader(value))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseMounts parses mounts written as source:target[:ro].
+// A source starting with a / is a bind mount, "tmpfs" a tmpfs mount and
+// anything else a named volume. A lone target creates an anonymous volume.
+func parseMounts(lines []string) ([]mount.Mount, error) {
+	mounts := make([]mount.Mount, 0, len(lines))
+	for _, line := range lines {
+		parts := strings.Split(line, ":")
+		m := mount.Mount{Type: mount.TypeVolume}
+		switch len(parts) {
+		case 1:
+			m.Target = parts[0]
+		case 3:
+			if parts[2] != "ro" && parts[2] != "rw" {
+				return nil, fmt.Errorf("invalid mount mode %q in %q", parts[2], line)
+			}
+			m.ReadOnly = parts[2] == "ro"
+			fallthrough
+		case 2:
+			m.Source, m.Target = parts[0], parts[1]
+		default:
+			return nil, fmt.Errorf("invalid mount %q", line)
+		}
+		switch {
+		case m.Source == "tmpfs":
+			m.Type, m.Source = mount.TypeTmpfs, ""
+		case filepath.IsAbs(m.Source):
+			m.Type = mount.TypeBind
+		}
+		if !filepath.IsAbs(m.Target) {
+			return nil, fmt.Errorf("mount target %q must be an absolute path", m.Target)
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// parseRestartPolicy parses a restart policy name and its maximum retry count.
+func parseRestartPolicy(name, maxRetries string) (container.RestartPolicy, error) {
+	policy := container.RestartPolicy{Name: name}
+	if name == "" {
+		return policy, nil
+	}
+	for _, p := range restartPolicies {
+		if p != name {
+			continue
+		}
+		if name == "on-failure" && maxRetries != "" {
+			retries, err := strconv.Atoi(maxRetries)
+			if err != nil || retries < 0 {
+				return policy, fmt.Errorf("invalid maximum retry count %q", maxRetries)
+			}
+			policy.MaximumRetryCount = retries
+		}
+		return policy, nil
+	}
+	return policy, fmt.Errorf("unknown restart policy %q", name)
+}
+
+// parseResources parses the CPU and memory limits of the run form.
+// Memory values accept units such as 512m or 2g.
+func parseResources(cpus, memory, memorySwap string) (container.Resources, error) {
+	var resources container.Resources
+	if cpus != "" {
+		value, err := strconv.ParseFloat(cpus, 64)
+		if err != nil || value < 0 {
+			return resources, fmt.Errorf("invalid CPUs value %q", cpus)
+		}
+		resources.NanoCPUs = int64(value * 1e9)
+	}
+	if memory != "" {
+		value, err := units.RAMInBytes(memory)
+		if err != nil {
+			return resources, err
+		}
+		resources.Memory = value
+	}
+	if memorySwap != "" {
+		value, err := units.RAMInBytes(memorySwap)
+		if err != nil {
+			return resources, err
+		}
+		resources.MemorySwap = value
+	}
+	return resources, nil
+}
+
+func (s *Server) handleImageRun() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	type runResponse struct {
+		ImageID         string
+		ImageName       string
+		Ports           string
+		Mounts          string
+		Env             string
+		Networks        []string
+		RestartPolicies []string
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		init.Do(func() {
+			tpl, err = s.parseTemplate("run.html")
+		})
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		imageID := mux.Vars(r)["id"]
+		image, _, err := s.docker.ImageInspectWithRaw(ctx, imageID)
+		if err != nil && err != context.Canceled {
+			logrus.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		networks, err := s.docker.NetworkList(ctx, types.NetworkListOptions{})
+		if err != nil && err != context.Canceled {
+			logrus.Error("Docker networks list", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := runResponse{
+			ImageID:         image.ID,
+			ImageName:       image.ID,
+			RestartPolicies: restartPolicies,
+		}
+		if len(image.RepoTags) > 0 {
+			response.ImageName = image.RepoTags[0]
+		}
+		if image.Config != nil {
+			ports := make([]string, 0, len(image.Config.ExposedPorts))
+			for port := range image.Config.ExposedPorts {
+				ports = append(ports, string(port))
+			}
+			sort.Strings(ports)
+			volumes := make([]string, 0, len(image.Config.Volumes))
+			for volume := range image.Config.Volumes {
+				volumes = append(volumes, volume)
+			}
+			sort.Strings(volumes)
+			response.Ports = strings.Join(ports, "\n")
+			response.Mounts = strings.Join(volumes, "\n")
+			response.Env = strings.Join(image.Config.Env, "\n")
+		}
+		for _, n := range networks {
+			response.Networks = append(response.Networks, n.Name)
+		}
+		sort.Strings(response.Networks)
+
+		err = tpl.ExecuteTemplate(w, "run.html", response)
+		if err != nil {
+			logrus.Error(err)
+		}
+	}
+}
+
+func (s *Server) handleContainerCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		exposedPorts, portBindings, err := nat.ParsePortSpecs(splitLines(r.PostForm.Get("ports")))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mounts, err := parseMounts(splitLines(r.PostForm.Get("mounts")))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		restartPolicy, err := parseRestartPolicy(r.PostForm.Get("restart"), r.PostForm.Get("max_retries"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resources, err := parseResources(r.PostForm.Get("cpus"), r.PostForm.Get("memory"), r.PostForm.Get("memory_swap"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		config := &container.Config{
+			Image:        r.PostForm.Get("image"),
+			Env:          splitLines(r.PostForm.Get("env")),
+			ExposedPorts: exposedPorts,
+		}
+		hostConfig := &container.HostConfig{
+			PortBindings:  portBindings,
+			Mounts:        mounts,
+			RestartPolicy: restartPolicy,
+			Resources:     resources,
+		}
+		if networkName := r.PostForm.Get("network"); networkName != "" {
+			hostConfig.NetworkMode = container.NetworkMode(networkName)
+		}
+
+		created, err := s.docker.ContainerCreate(ctx, config, hostConfig, &network.NetworkingConfig{}, r.PostForm.Get("name"))
+		if err != nil && err != context.Canceled {
+			logrus.Error("Docker container create", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, warning := range created.Warnings {
+			logrus.Warn(warning)
+		}
+		err = s.docker.ContainerStart(ctx, created.ID, types.ContainerStartOptions{})
+		if err != nil && err != context.Canceled {
+			logrus.Error("Docker container start", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/containers/"+created.ID, http.StatusSeeOther)
+	}
+}
@@ -2,36 +2,63 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"html/template"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/blevesearch/bleve"
 	"github.com/blevesearch/bleve/search"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	containersIndexName = "containers"
 	imagesIndexName     = "images"
+	volumesIndexName    = "volumes"
+	networksIndexName   = "networks"
+	projectsIndexName   = "projects"
+	searchResultsSize   = 100
+	searchFacetsSize    = 10
+	// indexReconcileInterval is the period of the full index reconciliation
+	indexReconcileInterval = 10 * time.Minute
+	// indexRetryInterval is the period of the reconciliation until one succeeds
+	indexRetryInterval = 30 * time.Second
+	// searchMappingVersion changes with the mapping of the search documents,
+	// the indexes of another version are rebuilt
+	searchMappingVersion    = "4"
+	searchMappingVersionKey = "mapping_version"
+	indexingState           = "indexing"
+	readyState              = "ready"
 )
 
-func splitResultByTypes(results search.DocumentMatchCollection) (containers []string, images []string) {
+// searchFacetFields are the fields whose values refine the search results
+var searchFacetFields = []string{"state", "image", "project"}
+
+// searchIndexNames are the search indexes, in the order results are shown
+var searchIndexNames = []string{containersIndexName, imagesIndexName, volumesIndexName, networksIndexName, projectsIndexName}
+
+func splitResultByTypes(results search.DocumentMatchCollection) map[string][]string {
+	ids := make(map[string][]string)
 	for _, result := range results {
 		switch result.Index {
-		case containersIndexName:
-			containers = append(containers, result.ID)
-		case imagesIndexName:
-			images = append(images, result.ID)
+		case containersIndexName, imagesIndexName, volumesIndexName, networksIndexName, projectsIndexName:
+			ids[result.Index] = append(ids[result.Index], result.ID)
 		default:
 			log.Error("Unknown index type")
 		}
 	}
-	return
+	return ids
 }
 
 func (s *Server) resolveContainers(containersID ...string) ([]types.Container, error) {
@@ -59,68 +86,483 @@ func (s *Server) resolveImages(imagesID ...string) ([]types.ImageInspect, error)
 	return images, nil
 }
 
-func (s *Server) buildIndex() error {
+func (s *Server) resolveNetworks(networksID ...string) []types.NetworkResource {
+	networks := make([]types.NetworkResource, 0, len(networksID))
+	for _, id := range networksID {
+		network, err := s.docker.NetworkInspect(context.Background(), id, types.NetworkInspectOptions{})
+		if err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// indexSync is the synchronisation state of the search indexes
+type indexSync struct {
+	mu      sync.Mutex
+	indexes map[string]bleve.Index
+	// ready is set once the indexes were reconciled with the host at least once
+	ready bool
+	// reopened is set when the indexes of a previous launch were reused
+	reopened      bool
+	reconciled    int
+	lastSync      time.Time
+	lastReconcile time.Time
+	lastError     string
+}
+
+func (i *indexSync) synced(reconciled bool, err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := time.Now()
+	if err != nil {
+		i.lastError = err.Error()
+		return
+	}
+	i.lastError = ""
+	i.lastSync = now
+	if reconciled {
+		i.lastReconcile = now
+		i.ready = true
+	}
+}
+
+// progress records how many indexes the running reconciliation went through.
+func (i *indexSync) progress(reconciled int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.reconciled = reconciled
+}
+
+func (i *indexSync) get(name string) bleve.Index {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.indexes[name]
+}
+
+func (i *indexSync) isReady() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.ready
+}
+
+// searchable reports whether the indexes can be searched: once reconciled,
+// or right away when reused from a previous launch.
+func (i *indexSync) searchable() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.ready || i.reopened
+}
+
+// searchIndex returns the alias of the search indexes, nil until they are opened.
+func (s *Server) searchIndex() bleve.Index {
+	s.indexSync.mu.Lock()
+	defer s.indexSync.mu.Unlock()
+	return s.index
+}
+
+// openSearchIndex opens an index kept in the cache directory. The index is
+// created when missing, and recreated when unreadable or mapped by another
+// version of the console. reopened reports whether it was reused.
+func openSearchIndex(name string) (index bleve.Index, reopened bool, err error) {
 	cacheDir, err := os.UserCacheDir()
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 	os.Mkdir(filepath.Join(cacheDir, applicationName), os.ModePerm)
-	containerIndexFilePath := filepath.Join(cacheDir, applicationName, "containers.index")
-	err = os.RemoveAll(containerIndexFilePath)
-	if err != nil {
+	indexFilePath := filepath.Join(cacheDir, applicationName, name+".index")
+	index, err = bleve.Open(indexFilePath)
+	if err == nil {
+		version, _ := index.GetInternal([]byte(searchMappingVersionKey))
+		if string(version) == searchMappingVersion {
+			index.SetName(name)
+			return index, true, nil
+		}
+		index.Close()
+		log.Infof("Recreating the %s index mapped by another version", name)
+	} else if err != bleve.ErrorIndexPathDoesNotExist {
+		log.Errorf("Recreating the unreadable %s index: %s", name, err)
+	}
+	if err := os.RemoveAll(indexFilePath); err != nil {
 		log.Error("New index", err)
 	}
-	containersIndex, err := bleve.New(containerIndexFilePath, bleve.NewIndexMapping())
+	indexMapping, err := newSearchIndexMapping()
 	if err != nil {
-		log.Error("New index", err)
-		return err
+		return nil, false, err
 	}
-	containersIndex.SetName(containersIndexName)
-	containers, err := s.docker.ContainerList(context.Background(), types.ContainerListOptions{All: true})
+	index, err = bleve.New(indexFilePath, indexMapping)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := index.SetInternal([]byte(searchMappingVersionKey), []byte(searchMappingVersion)); err != nil {
+		return nil, false, err
+	}
+	index.SetName(name)
+	return index, false, nil
+}
+
+// openIndex opens the search indexes, they can be searched from then on.
+func (s *Server) openIndex() error {
+	indexes := make(map[string]bleve.Index, len(searchIndexNames))
+	aliased := make([]bleve.Index, 0, len(searchIndexNames))
+	reopened := true
+	for _, name := range searchIndexNames {
+		index, indexReopened, err := openSearchIndex(name)
+		if err != nil {
+			return err
+		}
+		indexes[name] = index
+		aliased = append(aliased, index)
+		reopened = reopened && indexReopened
+	}
+	s.indexSync.mu.Lock()
+	defer s.indexSync.mu.Unlock()
+	s.indexSync.indexes = indexes
+	s.indexSync.reopened = reopened
+	s.index = bleve.NewIndexAlias(aliased...)
+	return nil
+}
+
+// indexedIDs returns the IDs of the documents of an index.
+func indexedIDs(index bleve.Index) (map[string]bool, error) {
+	count, err := index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(count), 0, false)
+	result, err := index.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(result.Hits))
+	for _, hit := range result.Hits {
+		ids[hit.ID] = true
+	}
+	return ids, nil
+}
+
+// syncDocuments indexes documents and deletes the stale ones in one batch.
+func syncDocuments(index bleve.Index, documents map[string]interface{}) error {
+	stale, err := indexedIDs(index)
 	if err != nil {
-		log.Error("Search container list", err)
 		return err
 	}
-	for _, container := range containers {
-		err = containersIndex.Index(container.ID, container)
+	batch := index.NewBatch()
+	for id, document := range documents {
+		if err := batch.Index(id, document); err != nil {
+			return err
+		}
+		delete(stale, id)
+	}
+	for id := range stale {
+		batch.Delete(id)
+	}
+	return index.Batch(batch)
+}
+
+// indexDocuments lists the documents of a search index by ID.
+func (s *Server) indexDocuments(ctx context.Context, name string) (map[string]interface{}, error) {
+	documents := make(map[string]interface{})
+	switch name {
+	case containersIndexName:
+		containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{All: true})
+		if err != nil {
+			return nil, err
+		}
+		for _, container := range containers {
+			documents[container.ID] = newContainerDocument(container, s.containerEnv(ctx, container.ID))
+		}
+	case imagesIndexName:
+		images, err := s.docker.ImageList(ctx, types.ImageListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range images {
+			documents[image.ID] = newImageDocument(image)
+		}
+	case volumesIndexName:
+		volumes, err := s.docker.VolumeList(ctx, filters.NewArgs())
+		if err != nil {
+			return nil, err
+		}
+		for _, volume := range volumes.Volumes {
+			documents[volume.Name] = newVolumeDocument(volume)
+		}
+	case networksIndexName:
+		networks, err := s.docker.NetworkList(ctx, types.NetworkListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, network := range networks {
+			documents[network.ID] = newNetworkDocument(network)
+		}
+	case projectsIndexName:
+		projects, err := s.composeProjects(ctx)
 		if err != nil {
-			log.Error("Container index error", err)
+			return nil, err
+		}
+		for _, project := range projects {
+			documents[project.Name] = project
+		}
+	}
+	return documents, nil
+}
+
+// containerEnv returns the environment of a container, which containers
+// lists lack.
+func (s *Server) containerEnv(ctx context.Context, containerID string) []string {
+	container, err := s.docker.ContainerInspect(ctx, containerID)
+	if err != nil || container.Config == nil {
+		return nil
+	}
+	return container.Config.Env
+}
+
+// composeProjects gathers the compose projects from the containers labels.
+func (s *Server) composeProjects(ctx context.Context) ([]composeProject, error) {
+	containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", composeProjectLabel)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*composeProject)
+	for _, container := range containers {
+		name := container.Labels[composeProjectLabel]
+		project, ok := byName[name]
+		if !ok {
+			project = &composeProject{Name: name}
+			byName[name] = project
+		}
+		project.Services = append(project.Services, container.Labels[composeServiceLabel])
+		project.Containers = append(project.Containers, container.Names[0][1:])
+	}
+	projects := make([]composeProject, 0, len(byName))
+	for _, project := range byName {
+		projects = append(projects, *project)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+// reconcileIndex indexes every object of the host and removes the
+// documents of the ones which no longer exist, catching up on missed events.
+func (s *Server) reconcileIndex() error {
+	ctx := context.Background()
+	for done, name := range searchIndexNames {
+		s.indexSync.progress(done)
+		if err := s.reconcileSearchIndex(ctx, name); err != nil {
 			return err
 		}
 	}
+	s.indexSync.progress(len(searchIndexNames))
+	return nil
+}
 
-	imagesIndexFilePath := filepath.Join(cacheDir, applicationName, "images.index")
-	err = os.RemoveAll(imagesIndexFilePath)
+func (s *Server) reconcileSearchIndex(ctx context.Context, name string) error {
+	documents, err := s.indexDocuments(ctx, name)
 	if err != nil {
-		log.Error("New index", err)
+		log.Errorf("Search %s list: %s", name, err)
+		return err
 	}
-	imagesIndex, err := bleve.New(imagesIndexFilePath, bleve.NewIndexMapping())
+	if err := syncDocuments(s.indexSync.get(name), documents); err != nil {
+		log.Errorf("Search %s index: %s", name, err)
+		return err
+	}
+	return nil
+}
+
+// indexContainer updates the document of a container, deleting it when
+// the container no longer exists.
+func (s *Server) indexContainer(containerID string) error {
+	index := s.indexSync.get(containersIndexName)
+	containers, err := s.resolveContainers(containerID)
 	if err != nil {
-		log.Error("New index", err)
 		return err
 	}
-	imagesIndex.SetName(imagesIndexName)
+	if len(containers) == 0 {
+		return index.Delete(containerID)
+	}
+	return index.Index(containerID, newContainerDocument(containers[0], s.containerEnv(context.Background(), containerID)))
+}
+
+// indexImage updates the document of an image, deleting it when the
+// image no longer exists.
+func (s *Server) indexImage(imageID string) error {
+	index := s.indexSync.get(imagesIndexName)
 	images, err := s.docker.ImageList(context.Background(), types.ImageListOptions{})
 	if err != nil {
-		log.Error("Search images list", err)
 		return err
 	}
 	for _, image := range images {
-		err = imagesIndex.Index(image.ID, image)
+		if image.ID == imageID {
+			return index.Index(imageID, newImageDocument(image))
+		}
+	}
+	return index.Delete(imageID)
+}
+
+// indexVolume updates the document of a volume, deleting it when the
+// volume no longer exists.
+func (s *Server) indexVolume(name string) error {
+	index := s.indexSync.get(volumesIndexName)
+	volume, err := s.docker.VolumeInspect(context.Background(), name)
+	if client.IsErrNotFound(err) {
+		return index.Delete(name)
+	}
+	if err != nil {
+		return err
+	}
+	return index.Index(name, newVolumeDocument(&volume))
+}
+
+// indexNetwork updates the document of a network, deleting it when the
+// network no longer exists.
+func (s *Server) indexNetwork(networkID string) error {
+	index := s.indexSync.get(networksIndexName)
+	network, err := s.docker.NetworkInspect(context.Background(), networkID, types.NetworkInspectOptions{})
+	if client.IsErrNotFound(err) {
+		return index.Delete(networkID)
+	}
+	if err != nil {
+		return err
+	}
+	return index.Index(networkID, newNetworkDocument(network))
+}
+
+// updateIndex applies a Docker event to the search indexes.
+func (s *Server) updateIndex(msg events.Message) {
+	var err error
+	switch msg.Type {
+	case events.ContainerEventType:
+		switch msg.Action {
+		case "create", "rename", "update", "destroy":
+			err = s.indexContainer(msg.Actor.ID)
+			if err == nil && msg.Actor.Attributes[composeProjectLabel] != "" {
+				err = s.reconcileSearchIndex(context.Background(), projectsIndexName)
+			}
+		case "start", "stop", "die", "kill", "pause", "unpause", "restart":
+			// The state and status of the container changed
+			err = s.indexContainer(msg.Actor.ID)
+		default:
+			return
+		}
+	case events.VolumeEventType:
+		switch msg.Action {
+		case "create", "destroy":
+			err = s.indexVolume(msg.Actor.ID)
+		default:
+			return
+		}
+	case events.NetworkEventType:
+		switch msg.Action {
+		case "create", "destroy":
+			err = s.indexNetwork(msg.Actor.ID)
+		default:
+			return
+		}
+	case events.ImageEventType:
+		switch msg.Action {
+		case "tag", "untag", "delete":
+			err = s.indexImage(msg.Actor.ID)
+		case "pull", "import", "load":
+			// The actor of these events is a reference rather than an image ID
+			err = s.reconcileSearchIndex(context.Background(), imagesIndexName)
+		default:
+			return
+		}
+	default:
+		return
+	}
+	if err != nil {
+		log.Error("Search index update", err)
+	}
+	s.indexSync.synced(false, err)
+}
+
+// syncIndex opens the search indexes, reconciles them with the host then
+// keeps them up to date from the Docker events, with a periodic
+// reconciliation. Until the first reconciliation succeeds it is retried
+// more often.
+func (s *Server) syncIndex(messages chan events.Message) {
+	if err := s.openIndex(); err != nil {
+		log.Error("Search index", err)
+		s.indexSync.synced(false, err)
+		s.broker.unsubscribe(messages)
+		return
+	}
+	s.indexSync.synced(true, s.reconcileIndex())
+	if index := s.searchIndex(); s.indexSync.isReady() {
+		docCount, _ := index.DocCount()
+		log.Infof("%d documents indexed.", docCount)
+	}
+	for {
+		next := indexReconcileInterval
+		if !s.indexSync.isReady() {
+			next = indexRetryInterval
+		}
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			s.updateIndex(msg)
+		case <-time.After(next):
+			s.indexSync.synced(true, s.reconcileIndex())
+		}
+	}
+}
+
+// IndexStatus reports the documents count and the freshness of the search indexes
+type IndexStatus struct {
+	// State is indexing until the first reconciliation, then ready
+	State string `json:"state"`
+	// Progress is the ratio of the indexes of the running reconciliation done
+	Progress      float64           `json:"progress"`
+	Reopened      bool              `json:"reopened"`
+	Documents     map[string]uint64 `json:"documents"`
+	LastSync      time.Time         `json:"last_sync"`
+	LastReconcile time.Time         `json:"last_reconcile"`
+	LastError     string            `json:"last_error,omitempty"`
+}
+
+// status returns the state of the search indexes.
+func (i *indexSync) status() IndexStatus {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	status := IndexStatus{
+		State:         indexingState,
+		Progress:      float64(i.reconciled) / float64(len(searchIndexNames)),
+		Reopened:      i.reopened,
+		Documents:     make(map[string]uint64, len(i.indexes)),
+		LastSync:      i.lastSync,
+		LastReconcile: i.lastReconcile,
+		LastError:     i.lastError,
+	}
+	if i.ready {
+		status.State = readyState
+	}
+	for name, index := range i.indexes {
+		count, err := index.DocCount()
 		if err != nil {
-			log.Error("Images index error", err)
-			return err
+			log.Error(err)
 		}
+		status.Documents[name] = count
 	}
+	return status
+}
 
-	s.index = bleve.NewIndexAlias(containersIndex, imagesIndex)
-	docCount, _ := s.index.DocCount()
-	log.Infof("%d containers indexed.", docCount)
-	return nil
+func (s *Server) handleSearchStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.indexSync.status()); err != nil {
+			log.Error(err)
+		}
+	}
 }
 
 func (s *Server) handleSearch() http.HandlerFunc {
-	go s.buildIndex()
 	var (
 		init sync.Once
 		tpl  *template.Template
@@ -134,10 +576,40 @@ func (s *Server) handleSearch() http.HandlerFunc {
 		ID   string
 		Name string
 	}
+	type volume struct {
+		Name   string
+		Driver string
+	}
+	type network struct {
+		ID     string
+		Name   string
+		Driver string
+	}
+	type project struct {
+		Name     string
+		Services []string
+	}
+	type facet struct {
+		Name     string
+		Count    int
+		URL      string
+		Selected bool
+	}
 	type searchResponse struct {
-		Hits       int
-		Containers []container
-		Images     []image
+		Query         string
+		Hits          int
+		StateFacets   []facet
+		ImageFacets   []facet
+		ProjectFacets []facet
+		Containers    []container
+		Images        []image
+		Volumes       []volume
+		Networks      []network
+		Projects      []project
+		// Searched is unset while the index isn't searchable yet
+		Searched bool
+		Status   IndexStatus
+		Progress int
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		init.Do(func() {
@@ -150,22 +622,28 @@ func (s *Server) handleSearch() http.HandlerFunc {
 		}
 
 		q := r.URL.Query().Get("q")
-		if q != "" {
-			search := bleve.NewSearchRequest(bleve.NewMatchQuery(q))
-			searchResults, err := s.index.Search(search)
+		status := s.indexSync.status()
+		index := s.searchIndex()
+		searchable := index != nil && s.indexSync.searchable()
+		if q != "" && searchable {
+			search := bleve.NewSearchRequestOptions(parseSearchQuery(q), searchResultsSize, 0, false)
+			for _, field := range searchFacetFields {
+				search.AddFacet(field, bleve.NewFacetRequest(field, searchFacetsSize))
+			}
+			searchResults, err := index.Search(search)
 			if err != nil {
 				log.Error(err)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			containersID, imagesID := splitResultByTypes(searchResults.Hits)
-			containers, err := s.resolveContainers(containersID...)
+			ids := splitResultByTypes(searchResults.Hits)
+			containers, err := s.resolveContainers(ids[containersIndexName]...)
 			if err != nil {
 				log.Error(err)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			images, err := s.resolveImages(imagesID...)
+			images, err := s.resolveImages(ids[imagesIndexName]...)
 			if err != nil {
 				log.Error(err)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -173,6 +651,9 @@ func (s *Server) handleSearch() http.HandlerFunc {
 			}
 
 			searchResponse := searchResponse{
+				Searched:   true,
+				Status:     status,
+				Query:      q,
 				Hits:       int(searchResults.Total),
 				Containers: make([]container, len(containers)),
 				Images:     make([]image, len(images)),
@@ -186,10 +667,64 @@ func (s *Server) handleSearch() http.HandlerFunc {
 					searchResponse.Images[index].Name = img.RepoTags[0]
 				}
 			}
+			for _, name := range ids[volumesIndexName] {
+				vol, err := s.docker.VolumeInspect(r.Context(), name)
+				if err == nil {
+					searchResponse.Volumes = append(searchResponse.Volumes, volume{Name: vol.Name, Driver: vol.Driver})
+				}
+			}
+			for _, n := range s.resolveNetworks(ids[networksIndexName]...) {
+				searchResponse.Networks = append(searchResponse.Networks, network{ID: n.ID, Name: n.Name, Driver: n.Driver})
+			}
+			if len(ids[projectsIndexName]) > 0 {
+				found := make(map[string]bool)
+				for _, name := range ids[projectsIndexName] {
+					found[name] = true
+				}
+				projects, err := s.composeProjects(r.Context())
+				if err != nil {
+					log.Error(err)
+				}
+				for _, p := range projects {
+					if found[p.Name] {
+						searchResponse.Projects = append(searchResponse.Projects, project{Name: p.Name, Services: p.Services})
+					}
+				}
+			}
+
+			facetsByField := map[string]*[]facet{
+				"state":   &searchResponse.StateFacets,
+				"image":   &searchResponse.ImageFacets,
+				"project": &searchResponse.ProjectFacets,
+			}
+			for field, facets := range facetsByField {
+				facetResult, ok := searchResults.Facets[field]
+				if !ok {
+					continue
+				}
+				for _, term := range facetResult.Terms {
+					clause := field + ":" + term.Term
+					selected := strings.Contains(q, clause)
+					refined := q
+					if !selected {
+						refined += " " + clause
+					}
+					*facets = append(*facets, facet{
+						Name:     term.Term,
+						Count:    term.Count,
+						URL:      "/search?" + url.Values{"q": {refined}}.Encode(),
+						Selected: selected,
+					})
+				}
+			}
 
 			err = tpl.ExecuteTemplate(w, "search.html", searchResponse)
 		} else {
-			err = tpl.ExecuteTemplate(w, "search.html", nil)
+			err = tpl.ExecuteTemplate(w, "search.html", searchResponse{
+				Query:    q,
+				Status:   status,
+				Progress: int(status.Progress * 100),
+			})
 		}
 		if err != nil {
 			log.Error(err)
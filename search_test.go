@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIndexSync(t *testing.T) {
+	var sync indexSync
+	if sync.searchable() {
+		t.Error("searchable before the first reconciliation")
+	}
+	sync.synced(true, errors.New("docker unavailable"))
+	if status := sync.status(); status.LastError != "docker unavailable" || status.State != indexingState {
+		t.Errorf("got %+v after a failed reconciliation", status)
+	}
+	sync.synced(false, nil)
+	if status := sync.status(); status.LastError != "" {
+		t.Errorf("last error %q not cleared by a successful update", status.LastError)
+	}
+	sync.synced(true, nil)
+	if status := sync.status(); status.State != readyState || status.LastReconcile.IsZero() || !sync.searchable() {
+		t.Errorf("got %+v after a reconciliation", status)
+	}
+
+	reopened := indexSync{reopened: true}
+	if !reopened.searchable() {
+		t.Error("indexes of a previous launch not searchable while reconciling")
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/mapping"
+	"github.com/docker/docker/api/types"
+)
+
+const (
+	containerDocumentType = "container"
+	imageDocumentType     = "image"
+	volumeDocumentType    = "volume"
+	networkDocumentType   = "network"
+	projectDocumentType   = "project"
+
+	// analyzedFieldSuffix names the analyzed copy of a keyword field
+	analyzedFieldSuffix = "_text"
+	// suggestFieldSuffix names the lowercase copy of a keyword field
+	suggestFieldSuffix = "_suggest"
+	// lowercaseKeywordAnalyzer indexes a whole value as a lowercase term
+	lowercaseKeywordAnalyzer = "keyword_lowercase"
+)
+
+// analyzedFields are the keyword fields also analyzed for free text search
+var analyzedFields = map[string]bool{"name": true, "image": true}
+
+// suggestFields are the keyword fields also indexed lowercase for the
+// case insensitive suggestions
+var suggestFields = map[string]bool{"name": true, "label": true}
+
+// containerDocument is a container as stored in the search index
+type containerDocument struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Image   string    `json:"image"`
+	State   string    `json:"state"`
+	Status  string    `json:"status"`
+	Command string    `json:"command"`
+	Project string    `json:"project"`
+	Service string    `json:"service"`
+	Labels  []string  `json:"label"`
+	Ports   []string  `json:"port"`
+	Env     []string  `json:"env"`
+}
+
+// Type implements bleve.Classifier
+func (d containerDocument) Type() string {
+	return containerDocumentType
+}
+
+// imageDocument is an image as stored in the search index
+type imageDocument struct {
+	Name    []string  `json:"name"`
+	Created time.Time `json:"created"`
+	Labels  []string  `json:"label"`
+}
+
+// Type implements bleve.Classifier
+func (d imageDocument) Type() string {
+	return imageDocumentType
+}
+
+// volumeDocument is a volume as stored in the search index
+type volumeDocument struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Driver  string    `json:"driver"`
+	Project string    `json:"project"`
+	Labels  []string  `json:"label"`
+}
+
+// Type implements bleve.Classifier
+func (d volumeDocument) Type() string {
+	return volumeDocumentType
+}
+
+// networkDocument is a network as stored in the search index
+type networkDocument struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Driver  string    `json:"driver"`
+	Project string    `json:"project"`
+	Labels  []string  `json:"label"`
+}
+
+// Type implements bleve.Classifier
+func (d networkDocument) Type() string {
+	return networkDocumentType
+}
+
+// composeProject is the search document of a compose project, gathered
+// from the labels of its containers
+type composeProject struct {
+	Name       string   `json:"name"`
+	Services   []string `json:"service"`
+	Containers []string `json:"container"`
+}
+
+// Type implements bleve.Classifier
+func (d composeProject) Type() string {
+	return projectDocumentType
+}
+
+// labelTerms indexes labels as key=value terms.
+func labelTerms(labels map[string]string) []string {
+	terms := make([]string, 0, len(labels))
+	for key, value := range labels {
+		terms = append(terms, key+"="+value)
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// envTerms indexes the environment variables as NAME=value terms, the
+// values of the ones looking like secrets are left out.
+func envTerms(env []string) []string {
+	terms := make([]string, len(env))
+	for index, variable := range env {
+		terms[index] = variable
+		if name := strings.SplitN(variable, "=", 2)[0]; secretPattern.MatchString(name) {
+			terms[index] = name + "="
+		}
+	}
+	return terms
+}
+
+func newContainerDocument(container types.Container, env []string) containerDocument {
+	document := containerDocument{
+		Name:    container.Names[0][1:],
+		Created: time.Unix(container.Created, 0),
+		Image:   container.Image,
+		State:   container.State,
+		Status:  container.Status,
+		Command: container.Command,
+		Project: container.Labels[composeProjectLabel],
+		Service: container.Labels[composeServiceLabel],
+		Labels:  labelTerms(container.Labels),
+		Env:     envTerms(env),
+	}
+	for _, port := range container.Ports {
+		document.Ports = append(document.Ports, fmt.Sprint(port.PrivatePort))
+		if port.PublicPort != 0 {
+			document.Ports = append(document.Ports, fmt.Sprint(port.PublicPort))
+		}
+	}
+	return document
+}
+
+func newImageDocument(image types.ImageSummary) imageDocument {
+	return imageDocument{Name: image.RepoTags, Created: time.Unix(image.Created, 0), Labels: labelTerms(image.Labels)}
+}
+
+func newVolumeDocument(volume *types.Volume) volumeDocument {
+	created, _ := time.Parse(time.RFC3339, volume.CreatedAt)
+	return volumeDocument{
+		Name:    volume.Name,
+		Created: created,
+		Driver:  volume.Driver,
+		Project: volume.Labels[composeProjectLabel],
+		Labels:  labelTerms(volume.Labels),
+	}
+}
+
+func newNetworkDocument(network types.NetworkResource) networkDocument {
+	return networkDocument{
+		Name:    network.Name,
+		Created: network.Created,
+		Driver:  network.Driver,
+		Project: network.Labels[composeProjectLabel],
+		Labels:  labelTerms(network.Labels),
+	}
+}
+
+// newSearchIndexMapping maps the fields of the search documents. Names,
+// labels and other identifiers are keywords so that they can be matched
+// exactly or with wildcards, and faceted on. Names and images are analyzed
+// as well, into the name_text and image_text fields, for free text to
+// match their words: nginx finds nginx-proxy, redis finds redis:5. Names
+// and labels are indexed lowercase too, into name_suggest and
+// label_suggest, to be completed whatever their case.
+func newSearchIndexMapping() (mapping.IndexMapping, error) {
+	keywordField := bleve.NewTextFieldMapping()
+	keywordField.Analyzer = keyword.Name
+	textField := bleve.NewTextFieldMapping()
+	dateField := bleve.NewDateTimeFieldMapping()
+	analyzedField := func(name string) *mapping.FieldMapping {
+		field := bleve.NewTextFieldMapping()
+		field.Name = name + analyzedFieldSuffix
+		field.Store = false
+		return field
+	}
+	suggestField := func(name string) *mapping.FieldMapping {
+		field := bleve.NewTextFieldMapping()
+		field.Name = name + suggestFieldSuffix
+		field.Analyzer = lowercaseKeywordAnalyzer
+		field.Store = false
+		field.IncludeInAll = false
+		return field
+	}
+
+	document := func(keywords []string, texts ...string) *mapping.DocumentMapping {
+		document := bleve.NewDocumentStaticMapping()
+		document.AddFieldMappingsAt("created", dateField)
+		for _, name := range keywords {
+			fields := []*mapping.FieldMapping{keywordField}
+			if analyzedFields[name] {
+				fields = append(fields, analyzedField(name))
+			}
+			if suggestFields[name] {
+				fields = append(fields, suggestField(name))
+			}
+			document.AddFieldMappingsAt(name, fields...)
+		}
+		for _, name := range texts {
+			document.AddFieldMappingsAt(name, textField)
+		}
+		return document
+	}
+
+	indexMapping := bleve.NewIndexMapping()
+	err := indexMapping.AddCustomAnalyzer(lowercaseKeywordAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{lowercase.Name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	indexMapping.AddDocumentMapping(containerDocumentType, document(
+		[]string{"name", "image", "state", "project", "service", "label", "port", "env"}, "status", "command"))
+	indexMapping.AddDocumentMapping(imageDocumentType, document([]string{"name", "label"}))
+	indexMapping.AddDocumentMapping(volumeDocumentType, document([]string{"name", "driver", "project", "label"}))
+	indexMapping.AddDocumentMapping(networkDocumentType, document([]string{"name", "driver", "project", "label"}))
+	indexMapping.AddDocumentMapping(projectDocumentType, document([]string{"name", "service", "container"}))
+	return indexMapping, nil
+}
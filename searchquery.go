@@ -0,0 +1,177 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// searchFields maps the fields of the search syntax to the indexed fields
+var searchFields = map[string]string{
+	"name":      "name",
+	"image":     "image",
+	"label":     "label",
+	"state":     "state",
+	"status":    "status",
+	"port":      "port",
+	"env":       "env",
+	"project":   "project",
+	"service":   "service",
+	"driver":    "driver",
+	"command":   "command",
+	"container": "container",
+}
+
+// ageUnits are the units of the age clauses, on top of the time.Duration ones
+var ageUnits = map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+
+// parseAge parses a duration such as 90m, 12h, 7d or 2w.
+func parseAge(value string) (time.Duration, error) {
+	for suffix, unit := range ageUnits {
+		if strings.HasSuffix(value, suffix) {
+			count, err := strconv.ParseFloat(strings.TrimSuffix(value, suffix), 64)
+			return time.Duration(count * float64(unit)), err
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+// ageClause translates age:>7d, objects created more than 7 days ago, or
+// age:<1h, objects created within the hour, into a created date range.
+func ageClause(value string, now time.Time) (string, bool) {
+	if len(value) < 2 || (value[0] != '<' && value[0] != '>') {
+		return "", false
+	}
+	age, err := parseAge(value[1:])
+	if err != nil {
+		return "", false
+	}
+	operator := "<"
+	if value[0] == '<' {
+		operator = ">"
+	}
+	return `created:` + operator + `"` + now.Add(-age).UTC().Format(time.RFC3339) + `"`, true
+}
+
+// searchKeyValueFields are indexed as key=value terms, a lone key matches
+// any value
+var searchKeyValueFields = map[string]bool{"label": true, "env": true}
+
+// queryStringEscaper escapes the characters of the bleve query string syntax
+// in an unquoted term, leaving the * and ? wildcards.
+var queryStringEscaper = strings.NewReplacer(
+	`\`, `\\`, `+`, `\+`, `-`, `\-`, `=`, `\=`, `&`, `\&`, `|`, `\|`, `>`, `\>`, `<`, `\<`,
+	`!`, `\!`, `(`, `\(`, `)`, `\)`, `{`, `\{`, `}`, `\}`, `[`, `\[`, `]`, `\]`, `^`, `\^`,
+	`"`, `\"`, `~`, `\~`, `:`, `\:`, `/`, `\/`, ` `, `\ `,
+)
+
+// splitSearchQuery splits a query on spaces, keeping quoted text together.
+func splitSearchQuery(q string) []string {
+	var (
+		tokens []string
+		token  strings.Builder
+		quoted bool
+	)
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			token.WriteRune(r)
+		case r == ' ' && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+// searchClause translates a clause of the search syntax, such as
+// label:env=prod, into the bleve query string syntax.
+func searchClause(clause string) string {
+	parts := strings.SplitN(clause, ":", 2)
+	if len(parts) == 2 && parts[0] == "age" {
+		if translated, ok := ageClause(parts[1], time.Now()); ok {
+			return translated
+		}
+	}
+	field, ok := searchFields[parts[0]]
+	if len(parts) != 2 || !ok || parts[1] == "" {
+		// Free text and the bleve syntax are kept as is
+		return clause
+	}
+	value := strings.Trim(parts[1], `"`)
+	if field == "state" {
+		value = strings.ToLower(value)
+	}
+	if searchKeyValueFields[field] && !strings.Contains(value, "=") {
+		value += "=*"
+	}
+	if strings.ContainsAny(value, "*?") {
+		return field + ":" + queryStringEscaper.Replace(value)
+	}
+	return field + `:"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// parseSearchQuery maps a query of the search syntax to bleve query string
+// queries. Clauses are field:value terms or free text, all of them must
+// match unless separated by OR, NOT or a leading - excludes a clause:
+//
+//	name:web* state:running label:env=prod OR image:redis NOT port:6379
+//	state:exited age:>7d
+func parseSearchQuery(q string) query.Query {
+	groups := [][]string{nil}
+	exclude := false
+	for _, token := range splitSearchQuery(q) {
+		switch token {
+		case "AND":
+			continue
+		case "OR":
+			if len(groups[len(groups)-1]) > 0 {
+				groups = append(groups, nil)
+			}
+			continue
+		case "NOT":
+			exclude = true
+			continue
+		}
+		prefix := "+"
+		if strings.HasPrefix(token, "-") {
+			exclude, token = true, token[1:]
+		} else {
+			token = strings.TrimPrefix(token, "+")
+		}
+		if token == "" {
+			continue
+		}
+		if exclude {
+			prefix = "-"
+		}
+		exclude = false
+		last := len(groups) - 1
+		groups[last] = append(groups[last], prefix+searchClause(token))
+	}
+
+	queries := make([]query.Query, 0, len(groups))
+	for _, group := range groups {
+		if len(group) > 0 {
+			queries = append(queries, bleve.NewQueryStringQuery(strings.Join(group, " ")))
+		}
+	}
+	switch len(queries) {
+	case 0:
+		return bleve.NewMatchNoneQuery()
+	case 1:
+		return queries[0]
+	}
+	return bleve.NewDisjunctionQuery(queries...)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/search/query"
+)
+
+// queryString describes the query string queries of a parsed search.
+func queryString(q query.Query) string {
+	switch q := q.(type) {
+	case *query.QueryStringQuery:
+		return q.Query
+	case *query.DisjunctionQuery:
+		disjuncts := make([]string, len(q.Disjuncts))
+		for index, disjunct := range q.Disjuncts {
+			disjuncts[index] = queryString(disjunct)
+		}
+		return strings.Join(disjuncts, " | ")
+	case *query.MatchNoneQuery:
+		return "<none>"
+	}
+	return "<unexpected>"
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{q: "", want: "<none>"},
+		{q: "AND OR", want: "<none>"},
+		{q: "nginx", want: "+nginx"},
+		{q: "name:web* state:Running", want: `+name:web* +state:"running"`},
+		{q: "name:my-app*", want: `+name:my\-app*`},
+		{q: `name:"my app"`, want: `+name:"my app"`},
+		{q: "label:env", want: `+label:env\=*`},
+		{q: "label:env=prod", want: `+label:"env=prod"`},
+		{q: "env:DEBUG=1 +port:80", want: `+env:"DEBUG=1" +port:"80"`},
+		{q: "image:redis OR image:nginx", want: `+image:"redis" | +image:"nginx"`},
+		{q: "OR state:running", want: `+state:"running"`},
+		{q: "state:running NOT port:6379 -project:demo", want: `+state:"running" -port:"6379" -project:"demo"`},
+		{q: "unknown:value name:", want: "+unknown:value +name:"},
+	}
+	for _, test := range tests {
+		t.Run(test.q, func(t *testing.T) {
+			if got := queryString(parseSearchQuery(test.q)); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAgeClause(t *testing.T) {
+	now := time.Date(2018, 11, 20, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{value: ">7d", want: `created:<"2018-11-13T12:00:00Z"`, ok: true},
+		{value: "<1h", want: `created:>"2018-11-20T11:00:00Z"`, ok: true},
+		{value: "<90m", want: `created:>"2018-11-20T10:30:00Z"`, ok: true},
+		{value: ">2w", want: `created:<"2018-11-06T12:00:00Z"`, ok: true},
+		{value: ">1.5d", want: `created:<"2018-11-19T00:00:00Z"`, ok: true},
+		{value: "7d"},
+		{value: ">"},
+		{value: ">soon"},
+		{value: "=1d"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, ok := ageClause(test.value, now)
+			if got != test.want || ok != test.ok {
+				t.Errorf("got %q, %t, want %q, %t", got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
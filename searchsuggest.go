@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blevesearch/bleve"
+	"github.com/blevesearch/bleve/search/query"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	suggestionsSize = 10
+	// suggestFuzziness is the edit distance tolerated by the fuzzy completions
+	suggestFuzziness = 1
+)
+
+// Suggestion is a completion of the search box
+type Suggestion struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// suggestionURL returns the page of a suggested object.
+func suggestionURL(indexName, id, text string) string {
+	switch indexName {
+	case containersIndexName:
+		return "/containers/" + id
+	case imagesIndexName:
+		return "/images/" + id
+	case volumesIndexName:
+		return "/volumes/" + url.PathEscape(id)
+	case networksIndexName:
+		return "/networks/" + id
+	case projectsIndexName:
+		return "/projects/" + url.PathEscape(id)
+	}
+	return "/search?" + url.Values{"q": {text}}.Encode()
+}
+
+// storedStrings returns the values of a stored field, which bleve returns
+// as a string for single values.
+func storedStrings(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// newSuggestQuery matches names and labels starting with q, then names
+// close to q, whatever their case. Prefix matches rank first.
+func newSuggestQuery(q string) query.Query {
+	q = strings.ToLower(q)
+	queries := make([]query.Query, 0, 3)
+	for _, field := range []string{"name", "label"} {
+		prefix := bleve.NewPrefixQuery(q)
+		prefix.SetField(field + suggestFieldSuffix)
+		prefix.SetBoost(2)
+		queries = append(queries, prefix)
+	}
+	fuzzy := bleve.NewFuzzyQuery(q)
+	fuzzy.SetField("name" + suggestFieldSuffix)
+	fuzzy.SetFuzziness(suggestFuzziness)
+	queries = append(queries, fuzzy)
+	return bleve.NewDisjunctionQuery(queries...)
+}
+
+// handleSearchSuggest completes the names of containers, images, volumes,
+// networks and compose projects, and labels, as JSON.
+func (s *Server) handleSearchSuggest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		suggestions := []Suggestion{}
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		index := s.searchIndex()
+		if q != "" && index != nil {
+			request := bleve.NewSearchRequestOptions(newSuggestQuery(q), suggestionsSize, 0, false)
+			request.Fields = []string{"name", "label"}
+			result, err := index.Search(request)
+			if err != nil {
+				log.Error(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			lower := strings.ToLower(q)
+			seen := make(map[string]bool)
+			add := func(suggestion Suggestion) {
+				if !seen[suggestion.URL] && len(suggestions) < suggestionsSize {
+					seen[suggestion.URL] = true
+					suggestions = append(suggestions, suggestion)
+				}
+			}
+			for _, hit := range result.Hits {
+				kind := strings.TrimSuffix(hit.Index, "s")
+				names := storedStrings(hit.Fields["name"])
+				// Prefer the names, tags of images, completing q
+				matched := false
+				for _, name := range names {
+					if strings.HasPrefix(strings.ToLower(name), lower) {
+						add(Suggestion{Text: name, Type: kind, URL: suggestionURL(hit.Index, hit.ID, name)})
+						matched = true
+					}
+				}
+				for _, label := range storedStrings(hit.Fields["label"]) {
+					if strings.HasPrefix(strings.ToLower(label), lower) {
+						text := "label:" + label
+						add(Suggestion{Text: text, Type: "label", URL: suggestionURL("", "", text)})
+						matched = true
+					}
+				}
+				if !matched && len(names) > 0 {
+					add(Suggestion{Text: names[0], Type: kind, URL: suggestionURL(hit.Index, hit.ID, names[0])})
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(suggestions); err != nil {
+			log.Error(err)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"html/template"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/blevesearch/bleve"
 	"github.com/docker/docker/api/types"
@@ -17,23 +18,104 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// Config holds the server settings
+type Config struct {
+	CrashLoopWindow    time.Duration
+	CrashLoopThreshold int
+	AlertWebhook       string
+	LogsCollector      bool
+	CollectLogs        []string
+	LogsRetention      time.Duration
+	LogsRetentionLines uint64
+	LogsRetentionSize  int64
+	ForwardLogs        []string
+	LogRules           []string
+	LogAlertInterval   time.Duration
+	LogsMeter          bool
+	LogsMaxSize        int64
+}
+
 type Server struct {
-	router    *mux.Router
-	templates packr.Box
-	docker    *client.Client
-	index     bleve.Index
+	router     *mux.Router
+	templates  packr.Box
+	docker     *client.Client
+	index      bleve.Index
+	indexSync  indexSync
+	audit      *auditLog
+	broker     *eventBroker
+	health     *healthTracker
+	crashes    *crashDetector
+	collector  *logCollector
+	forwarders []*logForwarder
+	rules      []logRule
+	alerter    *logAlerter
+	meter      *logsMeter
+	views      *viewStore
+	bulk       bulkJobs
+	// diskUsageSnapshot caches the disk usage, slow to compute
+	diskUsageSnapshot diskUsageSnapshot
+	// logsMaxSize is the json-file log size above which a container is warned about
+	logsMaxSize int64
 }
 
-func NewServer() (*Server, error) {
+func NewServer(config Config) (*Server, error) {
+	if err := validateCrashLoop(config.CrashLoopWindow, config.CrashLoopThreshold); err != nil {
+		return nil, err
+	}
 	dockerClient, err := client.NewEnvClient()
 	if err != nil {
 		return nil, err
 	}
 	s := &Server{
-		router:    mux.NewRouter(),
-		docker:    dockerClient,
-		templates: packr.NewBox("./templates"),
+		router:      mux.NewRouter(),
+		docker:      dockerClient,
+		templates:   packr.NewBox("./templates"),
+		audit:       newAuditLog(),
+		broker:      newEventBroker(),
+		health:      newHealthTracker(),
+		crashes:     newCrashDetector(config.CrashLoopWindow, config.CrashLoopThreshold, config.AlertWebhook),
+		logsMaxSize: config.LogsMaxSize,
+	}
+	s.views, err = openViewStore()
+	if err != nil {
+		// The console starts with no views rather than not at all
+		log.Error("Views store", err)
+		s.views = &viewStore{}
 	}
+	go s.health.watch(s.broker.subscribe())
+	go s.crashes.watch(s.broker.subscribe())
+	if config.LogsCollector || len(config.CollectLogs) > 0 {
+		s.collector, err = newLogCollector(context.Background(), s, config.CollectLogs, config.LogsRetention, config.LogsRetentionLines, config.LogsRetentionSize)
+		if err != nil {
+			return nil, err
+		}
+		go s.collector.run(s.broker.subscribe())
+	}
+	for _, address := range config.ForwardLogs {
+		forwarder, err := newLogForwarder(context.Background(), s, address)
+		if err != nil {
+			return nil, err
+		}
+		s.forwarders = append(s.forwarders, forwarder)
+		go forwarder.run(context.Background(), s.broker.subscribe())
+	}
+	s.rules, err = parseLogRules(config.LogRules, config.AlertWebhook)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range s.rules {
+		if rule.Alert {
+			s.alerter = newLogAlerter(context.Background(), s, s.rules, config.LogAlertInterval)
+			go s.alerter.run(s.broker.subscribe())
+			break
+		}
+	}
+	if config.LogsMeter {
+		s.meter = newLogsMeter(context.Background(), s)
+		go s.meter.run(s.broker.subscribe())
+	}
+	go s.syncIndex(s.broker.subscribe())
+	go s.watchEvents(context.Background())
 	s.routes()
 	return s, nil
 }
@@ -59,40 +141,18 @@ func (s *Server) parseTemplate(name string) (*template.Template, error) {
 	return partialsTemplate.New(name).Parse(templateFile)
 }
 
-func sumVolumesSize(volumes []*types.Volume) (sum int) {
-	for i := range volumes {
-		sum += int(volumes[i].UsageData.Size)
-	}
-	return sum
-}
-
-func sumImagesSize(images []*types.ImageSummary) (sum int) {
-	for i := range images {
-		sum += int(images[i].Size)
-	}
-	return sum
-}
-
-func sumContainersSize(containers []*types.Container) (sum int) {
-	for i := range containers {
-		sum += int(containers[i].SizeRootFs)
-	}
-	return sum
-}
-
 func (s *Server) handleIndex() http.HandlerFunc {
 	var (
-		init           sync.Once
-		tpl            *template.Template
-		err            error
-		diskUsageCache types.DiskUsage
+		init sync.Once
+		tpl  *template.Template
+		err  error
 	)
 	type response struct {
-		Info           types.Info
-		LayersSize     int
-		VolumesSize    int
-		ImagesSize     int
-		ContainersSize int
+		Info         types.Info
+		DiskUsage    []DiskCategory
+		DiskUsageAge time.Duration
+		DiskUsageErr string
+		Alerts       []ContainerAlert
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -105,28 +165,28 @@ func (s *Server) handleIndex() http.HandlerFunc {
 			return
 		}
 
-		diskUsage, err := s.docker.DiskUsage(ctx)
-		if err != nil && err != context.Canceled {
-			logrus.Error("Docker disk usage", err)
-			diskUsage = diskUsageCache
-		} else {
-			diskUsageCache = diskUsage
-		}
-
 		info, err := s.docker.Info(ctx)
 		if err != nil && err != context.Canceled {
 			logrus.Error("Docker info", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		err = tpl.ExecuteTemplate(w, "index.html", response{
-			Info:           info,
-			LayersSize:     int(diskUsage.LayersSize),
-			VolumesSize:    sumVolumesSize(diskUsage.Volumes),
-			ImagesSize:     sumImagesSize(diskUsage.Images),
-			ContainersSize: sumContainersSize(diskUsage.Containers),
-		})
+		indexResponse := response{
+			Info:   info,
+			Alerts: s.crashes.flagged(),
+		}
+		// The system page still shows when the disk usage fails, with the error
+		diskUsage, computed, err := s.diskUsage(ctx, false)
 		if err != nil {
+			logrus.Error("Docker disk usage", err)
+			indexResponse.DiskUsageErr = err.Error()
+		}
+		// The previous disk usage is shown when computing it failed
+		if !computed.IsZero() {
+			indexResponse.DiskUsage = diskCategories(diskUsage)
+			indexResponse.DiskUsageAge = time.Since(computed).Round(time.Second)
+		}
+		if err := tpl.ExecuteTemplate(w, "index.html", indexResponse); err != nil {
 			logrus.Error(err)
 		}
 	}
@@ -162,12 +222,21 @@ func (s *Server) handleEvents() http.HandlerFunc {
 			log.Println("HTTP connection just closed.")
 		}()
 
+		var alerts chan LogAlert
+		if s.alerter != nil {
+			alerts = s.alerter.subscribe()
+			defer s.alerter.unsubscribe(alerts)
+		}
+
 		eventChan, errChan := s.docker.Events(ctx, types.EventsOptions{
 			Filters: filters.NewArgs(
 				filters.Arg("type", "container"),
 				filters.Arg("type", "image"),
 				filters.Arg("event", "start"),
 				filters.Arg("event", "stop"),
+				filters.Arg("event", healthStatusAction),
+				filters.Arg("event", "die"),
+				filters.Arg("event", "oom"),
 			),
 		})
 		for {
@@ -183,6 +252,14 @@ func (s *Server) handleEvents() http.HandlerFunc {
 				}
 				fmt.Fprint(w, event)
 				f.Flush()
+			case alert := <-alerts:
+				event, err := logAlertEvent(alert)
+				if err != nil {
+					log.Error(err)
+					continue
+				}
+				fmt.Fprint(w, event)
+				f.Flush()
 			case err, ok := <-errChan:
 				if !ok {
 					return
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blevesearch/bleve"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// View is a named search query
+type View struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// URL returns the search page of the view.
+func (v View) URL() string {
+	return "/search?" + url.Values{"q": {v.Query}}.Encode()
+}
+
+// viewStore keeps the saved views in a JSON file of the user config directory
+type viewStore struct {
+	mu    sync.Mutex
+	path  string
+	views []View
+}
+
+func openViewStore() (*viewStore, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	os.MkdirAll(filepath.Join(configDir, applicationName), os.ModePerm)
+	store := &viewStore{path: filepath.Join(configDir, applicationName, "views.json")}
+	data, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return store, json.Unmarshal(data, &store.views)
+}
+
+// errViewsNotSaved is returned when the views store couldn't be opened
+var errViewsNotSaved = errors.New("the views store couldn't be opened, views aren't saved")
+
+// write saves views and keeps them once saved, the lock must be held.
+func (v *viewStore) write(views []View) error {
+	if v.path == "" {
+		return errViewsNotSaved
+	}
+	data, err := json.MarshalIndent(views, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written aside then renamed so that a crash never leaves a truncated file
+	if err := ioutil.WriteFile(v.path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(v.path+".tmp", v.path); err != nil {
+		return err
+	}
+	v.views = views
+	return nil
+}
+
+// list returns the views sorted by name.
+func (v *viewStore) list() []View {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	views := append([]View(nil), v.views...)
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+	return views
+}
+
+func (v *viewStore) get(name string) (View, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, view := range v.views {
+		if view.Name == name {
+			return view, true
+		}
+	}
+	return View{}, false
+}
+
+// save adds a view or replaces the query of the view of the same name.
+func (v *viewStore) save(view View) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	views := append([]View(nil), v.views...)
+	for index := range views {
+		if views[index].Name == view.Name {
+			views[index] = view
+			return v.write(views)
+		}
+	}
+	return v.write(append(views, view))
+}
+
+func (v *viewStore) delete(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for index := range v.views {
+		if v.views[index].Name == name {
+			views := append([]View(nil), v.views[:index]...)
+			return v.write(append(views, v.views[index+1:]...))
+		}
+	}
+	return nil
+}
+
+// countView returns the number of objects matching a view, -1 while the
+// search index isn't searchable.
+func (s *Server) countView(view View) int {
+	index := s.searchIndex()
+	if index == nil || !s.indexSync.searchable() {
+		return -1
+	}
+	request := bleve.NewSearchRequestOptions(parseSearchQuery(view.Query), 0, 0, false)
+	result, err := index.Search(request)
+	if err != nil {
+		log.Error("View count", err)
+		return -1
+	}
+	return int(result.Total)
+}
+
+// handleViews lists the saved views with their counts, as JSON when asked.
+func (s *Server) handleViews() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	type view struct {
+		Name  string `json:"name"`
+		Query string `json:"query"`
+		URL   string `json:"url"`
+		Count int    `json:"count"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		init.Do(func() {
+			tpl, err = s.parseTemplate("views.html")
+		})
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		views := []view{}
+		for _, v := range s.views.list() {
+			views = append(views, view{Name: v.Name, Query: v.Query, URL: v.URL(), Count: s.countView(v)})
+		}
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(views); err != nil {
+				log.Error(err)
+			}
+			return
+		}
+		if err := tpl.ExecuteTemplate(w, "views.html", views); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// handleViewSave saves the query of the search page as a view.
+func (s *Server) handleViewSave() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		view := View{
+			Name:  strings.TrimSpace(r.FormValue("name")),
+			Query: strings.TrimSpace(r.FormValue("q")),
+		}
+		if view.Name == "" || view.Query == "" {
+			err := errors.New("a view needs a name and a query")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.views.save(view); err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, view.URL(), http.StatusSeeOther)
+	}
+}
+
+func (s *Server) handleViewDelete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.views.delete(mux.Vars(r)["name"]); err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/views", http.StatusSeeOther)
+	}
+}
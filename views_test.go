@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestViewStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &viewStore{path: filepath.Join(dir, "views.json")}
+	for _, view := range []View{{"web", "name:web*"}, {"db", "image:postgres"}, {"web", "name:web-*"}} {
+		if err := store.save(view); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.delete("db"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "[\n  {\n    \"name\": \"web\",\n    \"query\": \"name:web-*\"\n  }\n]"; got != want {
+		t.Errorf("saved %s, want %s", got, want)
+	}
+
+	// The store which couldn't be opened has no views and keeps none
+	unopened := &viewStore{}
+	if err := unopened.save(View{"web", "name:web*"}); err != errViewsNotSaved {
+		t.Errorf("got error %v, want %v", err, errViewsNotSaved)
+	}
+	if views := unopened.list(); len(views) != 0 {
+		t.Errorf("got views %v of a store not saved", views)
+	}
+}
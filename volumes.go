@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"html/template"
 	"net/http"
-	"sort"
 	"sync"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
@@ -17,10 +21,12 @@ func (s *Server) handleVolumes() http.HandlerFunc {
 		err  error
 	)
 	type volume struct {
-		ID      string
-		Name    string
-		Created string
-		Size    int
+		Name    string `json:"name"`
+		Driver  string `json:"driver"`
+		Created string `json:"created"`
+		Size    int    `json:"size"`
+		// RefCount is the number of containers using the volume
+		RefCount int `json:"ref_count"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -32,6 +38,11 @@ func (s *Server) handleVolumes() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		query, err := parseListQuery(r, []string{"name", "created", "size"}, "size", descendingOrder)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		diskUsage, err := s.docker.DiskUsage(ctx)
 		if err != nil && err != context.Canceled {
 			logrus.Error("Docker disk usage", err)
@@ -39,22 +50,111 @@ func (s *Server) handleVolumes() http.HandlerFunc {
 			return
 		}
 
-		volumesResponse := make([]volume, len(diskUsage.Volumes))
-		for index, vol := range diskUsage.Volumes {
-			volumesResponse[index] = volume{
-				Name:    vol.Name,
-				Created: vol.CreatedAt,
-				Size:    int(vol.UsageData.Size),
+		// The disk usage can't be filtered, volumes are filtered here
+		volumesResponse := make([]volume, 0, len(diskUsage.Volumes))
+		for _, vol := range diskUsage.Volumes {
+			if vol.UsageData == nil {
+				vol.UsageData = &types.VolumeUsageData{Size: -1, RefCount: -1}
 			}
+			if (query.Driver != "" && vol.Driver != query.Driver) || !query.matchLabels(vol.Labels) {
+				continue
+			}
+			if query.Dangling != "" && (vol.UsageData.RefCount == 0) != (query.Dangling == "true") {
+				continue
+			}
+			volumesResponse = append(volumesResponse, volume{
+				Name:     vol.Name,
+				Driver:   vol.Driver,
+				Created:  vol.CreatedAt,
+				Size:     int(vol.UsageData.Size),
+				RefCount: int(vol.UsageData.RefCount),
+			})
 		}
 
-		sort.Slice(volumesResponse, func(i, j int) bool {
-			return volumesResponse[i].Size > volumesResponse[j].Size
+		query.sort(volumesResponse, map[string]func(i, j int) bool{
+			"name":    func(i, j int) bool { return volumesResponse[i].Name < volumesResponse[j].Name },
+			"created": func(i, j int) bool { return volumesResponse[i].Created < volumesResponse[j].Created },
+			"size":    func(i, j int) bool { return volumesResponse[i].Size < volumesResponse[j].Size },
 		})
+		start, end, page := query.paginate(len(volumesResponse))
+		page.Items = volumesResponse[start:end]
 
-		err = tpl.ExecuteTemplate(w, "volumes.html", volumesResponse)
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(page); err != nil {
+				logrus.Error(err)
+			}
+			return
+		}
+		if err := tpl.ExecuteTemplate(w, "volumes.html", page); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
+
+func (s *Server) handleVolume() http.HandlerFunc {
+	var (
+		init sync.Once
+		tpl  *template.Template
+		err  error
+	)
+	type container struct {
+		ID   string
+		Name string
+	}
+	type volumeResponse struct {
+		Name       string
+		Driver     string
+		Mountpoint string
+		Scope      string
+		Created    string
+		Labels     map[string]string
+		Options    map[string]string
+		Containers []container
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		init.Do(func() {
+			tpl, err = s.parseTemplate("volume.html")
+		})
 		if err != nil {
 			logrus.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		vol, err := s.docker.VolumeInspect(ctx, mux.Vars(r)["name"])
+		if client.IsErrNotFound(err) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{
+			All:     true,
+			Filters: filters.NewArgs(filters.Arg("volume", vol.Name)),
+		})
+		if err != nil {
+			logrus.Error(err)
+		}
+
+		response := volumeResponse{
+			Name:       vol.Name,
+			Driver:     vol.Driver,
+			Mountpoint: vol.Mountpoint,
+			Scope:      vol.Scope,
+			Created:    vol.CreatedAt,
+			Labels:     vol.Labels,
+			Options:    vol.Options,
+			Containers: make([]container, len(containers)),
+		}
+		for index, c := range containers {
+			response.Containers[index] = container{ID: c.ID, Name: c.Names[0][1:]}
+		}
+		if err := tpl.ExecuteTemplate(w, "volume.html", response); err != nil {
+			logrus.Error(err)
 		}
 	}
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	eventsReconnectDelay = 5 * time.Second
+	subscriberBufferSize = 64
+)
+
+// eventBroker fans Docker events out to the console background workers
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan events.Message]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[chan events.Message]struct{})}
+}
+
+func (b *eventBroker) subscribe() chan events.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan events.Message, subscriberBufferSize)
+	b.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (b *eventBroker) unsubscribe(ch chan events.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish never blocks, events are dropped for subscribers lagging behind.
+func (b *eventBroker) publish(msg events.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		default:
+			log.Warnf("Dropping %s %s event for a slow subscriber", msg.Type, msg.Action)
+		}
+	}
+}
+
+// watchEvents publishes every Docker event until ctx is done,
+// reconnecting to the daemon when the stream fails.
+func (s *Server) watchEvents(ctx context.Context) {
+	for {
+		eventChan, errChan := s.docker.Events(ctx, types.EventsOptions{})
+	stream:
+		for {
+			select {
+			case msg := <-eventChan:
+				s.broker.publish(msg)
+			case err := <-errChan:
+				if ctx.Err() != nil {
+					return
+				}
+				log.Error("Docker events", err)
+				break stream
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventsReconnectDelay):
+		}
+	}
+}